@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-orb/go-orb/log"
+	"github.com/octocompose/octoctl/pkg/octoconfig"
+	"github.com/urfave/cli/v3"
+)
+
+func configBlame(ctx context.Context, cmd *cli.Command) error {
+	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
+	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
+
+	entries := cfg.Provenance.Blame(cmd.Args().First())
+
+	switch cmd.String("format") {
+	case diffFormatHuman:
+		for _, entry := range entries {
+			value, err := json.Marshal(entry.Origin.Value)
+			if err != nil {
+				return fmt.Errorf("while marshaling value of '%s': %w", entry.Path, err)
+			}
+
+			//nolint:forbidigo
+			fmt.Printf("%s: %s\t# %s\n", entry.Path, value, entry.Origin)
+
+			for _, shadowed := range entry.Shadowed {
+				value, err := json.Marshal(shadowed.Value)
+				if err != nil {
+					return fmt.Errorf("while marshaling value of '%s': %w", entry.Path, err)
+				}
+
+				//nolint:forbidigo
+				fmt.Printf("    shadows %s\t# %s\n", value, shadowed)
+			}
+		}
+	case diffFormatJSON:
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("while marshaling provenance: %w", err)
+		}
+
+		//nolint:forbidigo
+		fmt.Println(string(b))
+	default:
+		logger.Error("Unknown format", "format", cmd.String("format"))
+		return fmt.Errorf("unknown format: %s", cmd.String("format"))
+	}
+
+	return nil
+}
@@ -11,7 +11,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -22,7 +21,14 @@ import (
 	"github.com/octocompose/octoctl/pkg/octoconfig"
 )
 
-func cloneRepo(logger log.Logger, cfg *octoconfig.Config, url *config.URL, referenceName string, forcePull bool) (string, error) {
+func cloneRepo(
+	ctx context.Context,
+	logger log.Logger,
+	cfg *octoconfig.Config,
+	url *config.URL,
+	referenceName string,
+	forcePull bool,
+) (string, error) {
 	sha256sum := sha256.Sum256([]byte(url.String()))
 
 	cachePath, err := octocache.Path(cfg.ProjectID, "build", hex.EncodeToString(sha256sum[:16]))
@@ -30,12 +36,25 @@ func cloneRepo(logger log.Logger, cfg *octoconfig.Config, url *config.URL, refer
 		return "", err
 	}
 
+	lock := octocache.LockFromContext(ctx)
+
+	pinned := ""
+	if lock != nil {
+		pinned = lock.Commit(url.String(), referenceName)
+	}
+
 	if _, err := os.Stat(cachePath); err != nil {
 		logger.Debug("Cloning git repository", "repository", url.String(), "cachePath", cachePath)
 
-		if _, err := git.PlainClone(cachePath, false, &git.CloneOptions{
+		depth := 1
+		if pinned != "" {
+			// The pinned commit might not be the tip of the reference.
+			depth = 0
+		}
+
+		if _, err := git.PlainCloneContext(ctx, cachePath, false, &git.CloneOptions{
 			URL:               url.String(),
-			Depth:             1,
+			Depth:             depth,
 			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 			Progress:          os.Stderr,
 			SingleBranch:      true,
@@ -45,7 +64,7 @@ func cloneRepo(logger log.Logger, cfg *octoconfig.Config, url *config.URL, refer
 		}
 	}
 
-	if forcePull { //nolint:nestif
+	if forcePull && pinned == "" { //nolint:nestif
 		logger.Debug("Pulling git repository", "repository", url.String(), "cachePath", cachePath)
 
 		r, err := git.PlainOpen(cachePath)
@@ -68,21 +87,88 @@ func cloneRepo(logger log.Logger, cfg *octoconfig.Config, url *config.URL, refer
 			RemoteName:        "origin",
 			ReferenceName:     plumbing.ReferenceName(referenceName),
 		})
-		if err != nil {
-			if errors.Is(err, git.NoErrAlreadyUpToDate) {
-				return cachePath, nil
-			}
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return "", err
+		}
+	}
 
+	if lock == nil {
+		return cachePath, nil
+	}
+
+	commit, err := pinRepo(logger, cachePath, pinned)
+	if errors.Is(err, errCommitNotFound) {
+		// Shallow clones from before the lockfile don't contain the commit, clone the full history.
+		logger.Debug("Pinned commit not found, cloning again", "repository", url.String(), "commit", pinned)
+
+		if err := os.RemoveAll(cachePath); err != nil {
+			return "", err
+		}
+
+		if _, err := git.PlainCloneContext(ctx, cachePath, false, &git.CloneOptions{
+			URL:               url.String(),
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+			Progress:          os.Stderr,
+			SingleBranch:      true,
+			ReferenceName:     plumbing.ReferenceName(referenceName),
+		}); err != nil {
 			return "", err
 		}
+
+		commit, err = pinRepo(logger, cachePath, pinned)
 	}
 
+	if err != nil {
+		return "", fmt.Errorf("while pinning '%s' to '%s': %w", url.String(), pinned, err)
+	}
+
+	lock.SetCommit(url.String(), referenceName, commit)
+
 	return cachePath, nil
 }
 
+// errCommitNotFound happens when a pinned commit is not part of a cloned repository.
+var errCommitNotFound = errors.New("pinned commit not found")
+
+// pinRepo checks out the pinned commit if given and returns the commit of HEAD.
+func pinRepo(logger log.Logger, cachePath string, pinned string) (string, error) {
+	r, err := git.PlainOpen(cachePath)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+
+	if pinned == "" || head.Hash().String() == pinned {
+		return head.Hash().String(), nil
+	}
+
+	logger.Debug("Checking out pinned commit", "path", cachePath, "commit", pinned)
+
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	hash := plumbing.NewHash(pinned)
+
+	if _, err := r.CommitObject(hash); err != nil {
+		return "", fmt.Errorf("%w: %w", errCommitNotFound, err)
+	}
+
+	if err := w.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return "", err
+	}
+
+	return pinned, nil
+}
+
 // renderBinaryName processes template variables in the binary name.
-func renderBinaryName(logger log.Logger, buildInfo *octoconfig.RepoSource, templateVars map[string]any) error {
-	t, err := template.New("build").Parse(buildInfo.Binary)
+func renderBinaryName(logger log.Logger, cfg *octoconfig.Config, buildInfo *octoconfig.RepoSource, templateVars map[string]any) error {
+	t, err := cfg.NewTemplate("source.binary").Parse(buildInfo.Binary)
 	if err != nil {
 		logger.Error("Error while parsing binary template", "error", err)
 		return fmt.Errorf("while parsing binary template: %w", err)
@@ -103,14 +189,15 @@ func renderBinaryName(logger log.Logger, buildInfo *octoconfig.RepoSource, templ
 func runBuildCommands(
 	ctx context.Context,
 	logger log.Logger,
+	cfg *octoconfig.Config,
 	buildInfo *octoconfig.RepoSource,
 	dir string,
 	templateVars map[string]any,
 ) error {
 	// Split the BuildCmds strings and execute each command
-	for _, cmdStr := range buildInfo.BuildCmds {
+	for cmdIdx, cmdStr := range buildInfo.BuildCmds {
 		// Process template
-		t, err := template.New("build").Parse(cmdStr)
+		t, err := cfg.NewTemplate(fmt.Sprintf("source.buildCmds[%d]", cmdIdx)).Parse(cmdStr)
 		if err != nil {
 			logger.Error("Error while parsing build command", "error", err)
 			return fmt.Errorf("while parsing build command: %w", err)
@@ -177,7 +264,7 @@ func build(
 ) (string, error) {
 	// Process template variables for binary name
 	templateVars := cfg.TemplateVars()
-	if err := renderBinaryName(logger, buildInfo, templateVars); err != nil {
+	if err := renderBinaryName(logger, cfg, buildInfo, templateVars); err != nil {
 		return "", err
 	}
 
@@ -196,7 +283,7 @@ func build(
 
 	// Clone repository if needed
 	if buildInfo.Path == nil {
-		dir, err = cloneRepo(logger, cfg, buildInfo.Repo, buildInfo.Ref, forceBuild)
+		dir, err = cloneRepo(ctx, logger, cfg, buildInfo.Repo, buildInfo.Ref, forceBuild)
 		if err != nil {
 			logger.Error("Error while cloning repository", "repository", buildInfo.Repo.String(), "error", err)
 			return "", err
@@ -216,7 +303,7 @@ func build(
 	}
 
 	// Run build commands
-	if err := runBuildCommands(ctx, logger, buildInfo, dir, templateVars); err != nil {
+	if err := runBuildCommands(ctx, logger, cfg, buildInfo, dir, templateVars); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/octocompose/octoctl/pkg/octooci"
+	"github.com/urfave/cli/v3"
+)
+
+// errChartPushArgs happens when `chart push` isn't called with a directory and a reference.
+var errChartPushArgs = errors.New("expected a directory and an oci:// reference")
+
+// errChartPushDir happens when the chart to push isn't a directory.
+var errChartPushDir = errors.New("not a directory")
+
+// chartPush publishes a directory as chart artifact to an OCI registry.
+func chartPush(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 { //nolint:mnd
+		return errChartPushArgs
+	}
+
+	dir := cmd.Args().Get(0)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%w: '%s'", errChartPushDir, dir)
+	}
+
+	ref, err := octooci.ParseReferenceString(cmd.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	if ref.File != "" || ref.IsDigest() {
+		return fmt.Errorf("%w '%s': expected 'oci://registry/repository:tag'", octooci.ErrReference, cmd.Args().Get(1))
+	}
+
+	client := octooci.NewClient(octooci.WithEnvironment())
+
+	digest, err := client.Push(ctx, ref, dir)
+	if err != nil {
+		return fmt.Errorf("while pushing '%s' to %s: %w", dir, ref.String(), err)
+	}
+
+	//nolint:forbidigo
+	fmt.Printf("Pushed %s@%s\n", ref.String(), digest)
+
+	return nil
+}
+
+func chartCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "chart",
+		Usage: "Manages charts published as OCI artifacts.",
+		Commands: []*cli.Command{
+			{
+				Name:      "push",
+				Usage:     "Publishes a directory as chart to an OCI registry.",
+				ArgsUsage: "dir oci://registry/repository:tag",
+				Action:    chartPush,
+			},
+		},
+	}
+}
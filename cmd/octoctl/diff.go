@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-orb/go-orb/log"
+	"github.com/octocompose/octoctl/pkg/octocache"
+	"github.com/octocompose/octoctl/pkg/octoconfig"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	diffFormatHuman = "human"
+	diffFormatYAML  = "yaml"
+	diffFormatJSON  = "json"
+)
+
+// errConfigsDiffer happens with `config diff --exit-code` when the configurations differ.
+var errConfigsDiffer = errors.New("configurations differ")
+
+// lastRunConfig reads the merged configuration written for the last operator run.
+func lastRunConfig(cfg *octoconfig.Config) (string, map[string]any, error) {
+	cfgPath, err := octocache.Path(cfg.ProjectID, "config.json")
+	if err != nil {
+		return "", nil, err
+	}
+
+	b, err := os.ReadFile(cfgPath) //nolint:gosec
+	if err != nil {
+		return "", nil, fmt.Errorf("while reading the configuration of the last run: %w", err)
+	}
+
+	data := map[string]any{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return "", nil, fmt.Errorf("while parsing the configuration of the last run '%s': %w", cfgPath, err)
+	}
+
+	return cfgPath, data, nil
+}
+
+// againstConfig returns the configuration to compare against.
+func againstConfig(ctx context.Context, cmd *cli.Command) (string, map[string]any, error) {
+	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
+	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
+
+	against := cmd.StringSlice("against")
+	if len(against) == 0 {
+		return lastRunConfig(cfg)
+	}
+
+	// Set the deadline for reading the configuration.
+	cfgCtx, cancel := context.WithTimeout(ctx, cmd.Duration("timeout"))
+	defer cancel()
+
+	other, err := newConfig(logger, cmd, against)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The other configuration is checked against its own lockfile, not the one of the primary configuration.
+	// The diff doesn't write it.
+	lock, err := loadLock(cmd, other)
+	if err != nil {
+		logger.Error("Error while loading the lockfile", "config", against, "error", err)
+		return "", nil, err
+	}
+
+	cfgCtx = octocache.WithLock(cfgCtx, lock)
+
+	if err := other.Run(cfgCtx); err != nil {
+		logger.Error("Error while running configuration", "config", against, "error", err)
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%v", against), other.Data, nil
+}
+
+func configDiff(ctx context.Context, cmd *cli.Command) error {
+	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
+	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
+
+	oldName, oldData, err := againstConfig(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	changes, err := octoconfig.Diff(oldData, cfg.Data)
+	if err != nil {
+		logger.Error("Error while comparing configurations", "error", err)
+		return err
+	}
+
+	switch cmd.String("format") {
+	case diffFormatHuman:
+		for _, change := range changes {
+			//nolint:forbidigo
+			fmt.Println(change.String())
+		}
+	case diffFormatYAML:
+		diff, err := octoconfig.UnifiedDiff(oldName, oldData, fmt.Sprintf("%v", cmd.StringSlice("config")), cfg.Data)
+		if err != nil {
+			logger.Error("Error while comparing configurations", "error", err)
+			return err
+		}
+
+		//nolint:forbidigo
+		fmt.Print(diff)
+	case diffFormatJSON:
+		if changes == nil {
+			changes = []octoconfig.Change{}
+		}
+
+		b, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("while marshaling changes: %w", err)
+		}
+
+		//nolint:forbidigo
+		fmt.Println(string(b))
+	default:
+		logger.Error("Unknown format", "format", cmd.String("format"))
+		return fmt.Errorf("unknown format: %s", cmd.String("format"))
+	}
+
+	if cmd.Bool("exit-code") && len(changes) > 0 {
+		return errConfigsDiffer
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// setFlag is a repeatable string flag which, unlike cli.StringSliceFlag, doesn't split its values at commas,
+// values of --set like `a={x,y}` contain them.
+type setFlag = cli.FlagBase[[]string, cli.NoConfig, setValues]
+
+// setValues collects the values of a setFlag.
+type setValues struct {
+	values *[]string
+}
+
+// Create implements cli.ValueCreator.
+func (setValues) Create(val []string, p *[]string, _ cli.NoConfig) cli.Value {
+	*p = append([]string{}, val...)
+
+	return &setValues{values: p}
+}
+
+// ToString implements cli.ValueCreator.
+func (setValues) ToString(val []string) string {
+	return strings.Join(val, ", ")
+}
+
+// Set appends value as is.
+func (s *setValues) Set(value string) error {
+	*s.values = append(*s.values, value)
+
+	return nil
+}
+
+// Get returns the values.
+func (s *setValues) Get() any {
+	return *s.values
+}
+
+// String returns the values.
+func (s *setValues) String() string {
+	if s.values == nil {
+		return ""
+	}
+
+	return s.ToString(*s.values)
+}
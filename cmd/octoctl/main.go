@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/go-orb/go-orb/codecs"
+	"github.com/go-orb/go-orb/config"
 	"github.com/go-orb/go-orb/log"
 	"github.com/octocompose/octoctl/pkg/octocache"
 	"github.com/octocompose/octoctl/pkg/octoconfig"
@@ -35,47 +36,155 @@ var Version = versioninfo.Short()
 type configKey struct{}
 type loggerKey struct{}
 
+// newConfig creates a configuration for paths with the global flags applied.
+func newConfig(logger log.Logger, cmd *cli.Command, paths []string) (*octoconfig.Config, error) {
+	logger.Debug("Creating configuration", "config", paths)
+
+	codec, err := codecs.GetMime(codecs.MimeYAML)
+	if err != nil {
+		logger.Error("Error while getting codec", "error", err)
+		return nil, err
+	}
+
+	hardCodedData := map[string]any{}
+	if err := codec.Unmarshal([]byte(hardcodedConfig), &hardCodedData); err != nil {
+		logger.Error("Error while marshaling configuration", "error", err)
+		return nil, err
+	}
+
+	cfg, err := octoconfig.New(
+		logger,
+		cmd.Bool("clear-cache"),
+		paths,
+		hardCodedData,
+		octoconfig.WithInsecureSkipVerify(cmd.Bool("insecure-skip-verify")),
+		octoconfig.WithKeyrings(cmd.StringSlice("keyring")...),
+		octoconfig.WithIncludeDedupe(cmd.String("include-dedupe")),
+		octoconfig.WithAgeKeyFile(cmd.String("age-key-file")),
+		octoconfig.WithProfiles(cmd.StringSlice("profile")...),
+		octoconfig.WithEnvironment(os.Environ()),
+		octoconfig.WithSet(octoconfig.SetJSON, cmd.StringSlice("set-json")...),
+		octoconfig.WithSet(octoconfig.SetFile, cmd.StringSlice("set-file")...),
+		octoconfig.WithSet(octoconfig.SetValue, cmd.StringSlice("set")...),
+		octoconfig.WithStrictTemplates(cmd.Bool("strict")),
+		octoconfig.WithProjectID(cmd.String("project")),
+		octoconfig.WithConcurrency(int(cmd.Int("jobs"))),
+		octoconfig.WithFetchTimeout(cmd.Duration("fetch-timeout")),
+	)
+	if err != nil {
+		logger.Error("Error while creating configuration", "error", err)
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// discoverConfig returns the config files given with --config or OCTOCTL_CONFIG, or discovered from the working directory.
+func discoverConfig(cmd *cli.Command) (*octoconfig.Discovery, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	return octoconfig.Discover(cmd.StringSlice("config"), os.Environ(), wd)
+}
+
+// configWhere prints the config files which get loaded and how they have been found.
+func configWhere(_ context.Context, cmd *cli.Command) error {
+	discovery, err := discoverConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range discovery.Paths {
+		//nolint:forbidigo
+		fmt.Printf("%s\t(%s)\n", path, discovery.Source)
+	}
+
+	return nil
+}
+
 func createConfig(ctx context.Context, cmd *cli.Command) (context.Context, error) {
 	logger, err := log.New(log.WithLevel(cmd.String("log-level")))
 	if err != nil {
 		return ctx, err
 	}
 
-	// Set timeout for Downloads
-	cfgCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+	// Set the deadline for reading the configuration.
+	cfgCtx, cancel := context.WithTimeout(ctx, cmd.Duration("timeout"))
 	defer cancel()
 
-	logger.Debug("Creating configuration", "config", cmd.StringSlice("config"))
-
-	codec, err := codecs.GetMime(codecs.MimeYAML)
+	discovery, err := discoverConfig(cmd)
 	if err != nil {
-		logger.Error("Error while getting codec", "error", err)
+		logger.Error("Error while looking for configuration files", "error", err)
 		return ctx, err
 	}
 
-	hardCodedData := map[string]any{}
-	if err := codec.Unmarshal([]byte(hardcodedConfig), &hardCodedData); err != nil {
-		logger.Error("Error while marshaling configuration", "error", err)
+	logger.Debug("Using configuration files", "paths", discovery.Paths, "source", discovery.Source)
+
+	cfg, err := newConfig(logger, cmd, discovery.Paths)
+	if err != nil {
 		return ctx, err
 	}
 
-	cfg, err := octoconfig.New(logger, cmd.Bool("clear-cache"), cmd.StringSlice("config"), hardCodedData)
+	lock, err := loadLock(cmd, cfg)
 	if err != nil {
-		logger.Error("Error while creating configuration", "error", err)
+		logger.Error("Error while loading the lockfile", "error", err)
 		return ctx, err
 	}
 
+	if lock != nil {
+		logger.Debug("Using lockfile", "path", lock.Path(), "update", lock.Updating())
+
+		ctx = octocache.WithLock(ctx, lock)
+		cfgCtx = octocache.WithLock(cfgCtx, lock)
+	}
+
 	if err := cfg.Run(cfgCtx); err != nil {
 		logger.Error("Error while running configuration", "error", err)
 		return ctx, err
 	}
 
+	if lock != nil {
+		if err := lock.Save(); err != nil {
+			logger.Error("Error while writing the lockfile", "error", err)
+			return ctx, err
+		}
+	}
+
 	ctx = context.WithValue(ctx, configKey{}, cfg)
 	ctx = context.WithValue(ctx, loggerKey{}, logger)
 
 	return ctx, nil
 }
 
+// loadLock reads the lockfile of the config, the `lock` command creates a new one if there is none or it gets updated.
+// Only the `lock` command adds new entries, other commands verify against the lockfile and leave it as it is.
+func loadLock(cmd *cli.Command, cfg *octoconfig.Config) (*octocache.Lock, error) {
+	lockPath, err := cfg.LockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := octocache.ReadLock(lockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Name != "lock" {
+		return lock, nil
+	}
+
+	if lock == nil || cmd.Bool("update") {
+		lock = octocache.NewLock(lockPath)
+		lock.SetUpdate(cmd.Bool("update"))
+	}
+
+	lock.SetPin(true)
+
+	return lock, nil
+}
+
 func configShow(ctx context.Context, cmd *cli.Command) error {
 	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
 	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
@@ -86,7 +195,52 @@ func configShow(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("unknown format: %s", cmd.String("format"))
 	}
 
-	b, err := codec.Marshal(cfg.Data)
+	data := cfg.Data
+
+	if cmd.Bool("signatures") {
+		signatures := []any{}
+
+		for _, signature := range cfg.Signatures {
+			sigData, err := config.ParseStruct(nil, signature)
+			if err != nil {
+				return fmt.Errorf("while parsing signature result: %w", err)
+			}
+
+			signatures = append(signatures, sigData)
+		}
+
+		data = map[string]any{"signatures": signatures}
+	}
+
+	if cmd.Bool("versions") {
+		versions := []any{}
+
+		for _, version := range cfg.ResolvedVersions {
+			versionData, err := config.ParseStruct(nil, version)
+			if err != nil {
+				return fmt.Errorf("while parsing resolved version: %w", err)
+			}
+
+			versions = append(versions, versionData)
+		}
+
+		data = map[string]any{"versions": versions}
+	}
+
+	if cmd.Bool("annotate") {
+		b, err := octoconfig.Annotate(data, cfg.Provenance)
+		if err != nil {
+			logger.Error("Error while annotating configuration", "error", err)
+			return fmt.Errorf("while annotating configuration: %w", err)
+		}
+
+		//nolint:forbidigo
+		fmt.Print(string(b))
+
+		return nil
+	}
+
+	b, err := codec.Marshal(data)
 	if err != nil {
 		logger.Error("Error while marshaling configuration", "error", err)
 		return fmt.Errorf("while marshaling configuration: %w", err)
@@ -98,20 +252,21 @@ func configShow(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
-func runOperator(ctx context.Context, cmd *cli.Command, args []string) error {
+// operatorPath returns the path of the operator binary, it downloads or builds the operator if required.
+func operatorPath(ctx context.Context, cmd *cli.Command) (string, error) {
 	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
 	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
 
 	forceBuild := cmd.Bool("force-build-operator")
 
 	if cfg.Octoctl.Operator == "" {
-		return errors.New("operator not specified")
+		return "", errors.New("operator not specified")
 	}
 
 	operatorRepo, ok := cfg.Repo.Operators[cfg.Octoctl.Operator]
 	if !ok {
 		logger.Error("Operator not found", "operator", cfg.Octoctl.Operator)
-		return fmt.Errorf("operator '%s' not found", cfg.Octoctl.Operator)
+		return "", fmt.Errorf("operator '%s' not found", cfg.Octoctl.Operator)
 	}
 
 	osArch := fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)
@@ -119,7 +274,7 @@ func runOperator(ctx context.Context, cmd *cli.Command, args []string) error {
 	binary, ok := operatorRepo.Binary[osArch]
 	if !ok && operatorRepo.Source == nil {
 		logger.Error("Operator not available for architecture", "operator", cfg.Octoctl.Operator, "osArch", osArch)
-		return fmt.Errorf("operator '%s' not available for %s", cfg.Octoctl.Operator, osArch)
+		return "", fmt.Errorf("operator '%s' not available for %s", cfg.Octoctl.Operator, osArch)
 	}
 
 	var execPath string
@@ -128,12 +283,12 @@ func runOperator(ctx context.Context, cmd *cli.Command, args []string) error {
 		url, err := octocache.CachedURL(ctx, cfg.ProjectID, binary.URL, binary.SHA256URL, "operators", false)
 		if err != nil {
 			logger.Error("Error while getting cached URL", "error", err)
-			return fmt.Errorf("while getting cached URL: %w", err)
+			return "", fmt.Errorf("while getting cached URL: %w", err)
 		}
 
 		if err := os.Chmod(url.Path, 0o700); err != nil {
 			logger.Error("Error while chmoding cached operator", "error", err)
-			return fmt.Errorf("while chmoding cached operator: %w", err)
+			return "", fmt.Errorf("while chmoding cached operator: %w", err)
 		}
 
 		logger.Debug("Using cached operator", "url", binary.URL, "cached", url.Path)
@@ -144,7 +299,7 @@ func runOperator(ctx context.Context, cmd *cli.Command, args []string) error {
 
 		path, err := build(ctx, logger, cfg, operatorRepo.Source, forceBuild)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		logger.Debug("Using git operator", "url", operatorRepo.Source.Repo, "path", path)
@@ -153,7 +308,26 @@ func runOperator(ctx context.Context, cmd *cli.Command, args []string) error {
 
 	if execPath == "" {
 		logger.Error("Operator not available for architecture", "operator", cfg.Octoctl.Operator, "osArch", osArch)
-		return fmt.Errorf("operator '%s' not available for %s", cfg.Octoctl.Operator, osArch)
+		return "", fmt.Errorf("operator '%s' not available for %s", cfg.Octoctl.Operator, osArch)
+	}
+
+	if lock := octocache.LockFromContext(ctx); lock != nil {
+		if err := lock.Save(); err != nil {
+			logger.Error("Error while writing the lockfile", "error", err)
+			return "", err
+		}
+	}
+
+	return execPath, nil
+}
+
+func runOperator(ctx context.Context, cmd *cli.Command, args []string) error {
+	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
+	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
+
+	execPath, err := operatorPath(ctx, cmd)
+	if err != nil {
+		return err
 	}
 
 	codec, err := codecs.GetMime(codecs.MimeJSON)
@@ -204,10 +378,14 @@ func main() {
 				Usage:   "Set the log level (debug, info, warn, error)",
 			},
 			&cli.StringSliceFlag{
-				Name:     "config",
-				Aliases:  []string{"c"},
-				Usage:    "Path to configuration files",
-				Required: true,
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "Path to configuration files, defaults to $OCTOCTL_CONFIG or octocompose.yaml in the working directory or its parents",
+			},
+			&cli.StringFlag{
+				Name:    "project",
+				Sources: cli.EnvVars("OCTOCTL_PROJECT"),
+				Usage:   "Project name, defaults to the name of the configuration or one derived from its path",
 			},
 			&cli.BoolFlag{
 				Name:  "force-build-operator",
@@ -217,6 +395,59 @@ func main() {
 				Name:  "clear-cache",
 				Usage: "Clear the cache.",
 			},
+			&cli.BoolFlag{
+				Name:  "insecure-skip-verify",
+				Usage: "Don't verify the GPG signatures of remote includes.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "keyring",
+				Usage: "Path to OpenPGP keyrings trusted for all includes",
+			},
+			&cli.IntFlag{
+				Name:    "jobs",
+				Aliases: []string{"j"},
+				Value:   octoconfig.DefaultConcurrency,
+				Sources: cli.EnvVars("OCTOCTL_JOBS"),
+				Usage:   "Number of includes, repositories and files fetched at the same time",
+			},
+			&cli.DurationFlag{
+				Name:    "fetch-timeout",
+				Value:   30 * time.Second,
+				Sources: cli.EnvVars("OCTOCTL_FETCH_TIMEOUT"),
+				Usage:   "Timeout of fetching a single include, repository or file, 0 disables it",
+			},
+			&cli.DurationFlag{
+				Name:    "timeout",
+				Value:   5 * time.Minute,
+				Sources: cli.EnvVars("OCTOCTL_TIMEOUT"),
+				Usage:   "Deadline for reading the whole configuration",
+			},
+			&cli.StringFlag{
+				Name:  "include-dedupe",
+				Value: octoconfig.IncludeDedupeFirst,
+				Usage: "Merge includes included more than once at their first, last or every occurrence (first, last, none)",
+			},
+			&cli.StringFlag{
+				Name:  "age-key-file",
+				Usage: "Path to the age key file to decrypt secrets, defaults to $OCTOCTL_AGE_KEY_FILE or ~/.config/octocompose/keys/age.txt",
+			},
+			&cli.StringSliceFlag{
+				Name:    "profile",
+				Aliases: []string{"p"},
+				Usage:   "Profiles to merge on top of the configuration, later profiles take precedence",
+			},
+			&setFlag{
+				Name:  "set",
+				Usage: "Set a value with path=value, takes precedence over all configuration files",
+			},
+			&setFlag{
+				Name:  "set-file",
+				Usage: "Set a value to the content of a file with path=file",
+			},
+			&setFlag{
+				Name:  "set-json",
+				Usage: "Set a value to a JSON value with path=json",
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -342,10 +573,45 @@ func main() {
 					return runOperator(ctx, cmd, args)
 				},
 			},
+			{
+				Name:  "lock",
+				Usage: "Writes the lockfile.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "update",
+						Usage: "Fetch everything again and replace the pinned entries.",
+					},
+				},
+				Before: createConfig,
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					logger := ctx.Value(loggerKey{}).(log.Logger) //nolint:errcheck
+
+					if _, err := operatorPath(ctx, cmd); err != nil {
+						return err
+					}
+
+					lock := octocache.LockFromContext(ctx)
+					if err := lock.Save(); err != nil {
+						logger.Error("Error while writing the lockfile", "error", err)
+						return err
+					}
+
+					logger.Info("Wrote lockfile", "path", lock.Path())
+
+					return nil
+				},
+			},
+			secretsCommand(),
+			chartCommand(),
 			{
 				Name:  "config",
 				Usage: "Manages the service configurations.",
 				Commands: []*cli.Command{
+					{
+						Name:   "where",
+						Usage:  "Shows which configuration files get loaded.",
+						Action: configWhere,
+					},
 					{
 						Name:  "show",
 						Usage: "Shows the merged configuration.",
@@ -356,13 +622,62 @@ func main() {
 								Value:   "yaml",
 								Usage:   "Output format (json, yaml, toml)",
 							},
+							&cli.BoolFlag{
+								Name:  "signatures",
+								Usage: "Show the signature verification results instead of the configuration.",
+							},
+							&cli.BoolFlag{
+								Name:  "versions",
+								Usage: "Show the versions chosen for versioned includes instead of the configuration.",
+							},
+							&cli.BoolFlag{
+								Name:  "annotate",
+								Usage: "Annotate each value with the file it has been set in, implies YAML.",
+							},
+							&cli.BoolFlag{
+								Name:  "strict",
+								Usage: "Report every template reference to a missing key as error.",
+							},
 						},
 						Before: createConfig,
 						Action: configShow,
 					},
+					{
+						Name:      "blame",
+						Usage:     "Shows which file set each value.",
+						ArgsUsage: "[path]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"f"},
+								Value:   diffFormatHuman,
+								Usage:   "Output format (human, json)",
+							},
+						},
+						Before: createConfig,
+						Action: configBlame,
+					},
 					{
 						Name:  "diff",
 						Usage: "Shows differences between configurations.",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "against",
+								Usage: "Configuration files to compare against, defaults to the configuration of the last operator run",
+							},
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"f"},
+								Value:   diffFormatHuman,
+								Usage:   "Output format (human, yaml, json)",
+							},
+							&cli.BoolFlag{
+								Name:  "exit-code",
+								Usage: "Exit with an error if the configurations differ.",
+							},
+						},
+						Before: createConfig,
+						Action: configDiff,
 					},
 				},
 			},
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"filippo.io/age"
+	"github.com/go-orb/go-orb/log"
+	"github.com/octocompose/octoctl/pkg/octoconfig"
+	"github.com/octocompose/octoctl/pkg/octosecrets"
+	"github.com/urfave/cli/v3"
+)
+
+// errNoSecretsFile happens when a secrets command is called without a file.
+var errNoSecretsFile = errors.New("no file given")
+
+// secretsFile returns the file argument of a secrets command and its content.
+func secretsFile(cmd *cli.Command) (string, []byte, error) {
+	path := cmd.Args().First()
+	if path == "" {
+		return "", nil, errNoSecretsFile
+	}
+
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return "", nil, fmt.Errorf("while reading '%s': %w", path, err)
+	}
+
+	return path, b, nil
+}
+
+// secretsKeys loads the age identities and the recipients to encrypt for.
+func secretsKeys(cmd *cli.Command) ([]age.Identity, []age.Recipient, error) {
+	identities, err := octosecrets.LoadIdentities(cmd.String("age-key-file"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recipients, err := octosecrets.Recipients(identities, cmd.StringSlice("recipient"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return identities, recipients, nil
+}
+
+// keyMatcher returns a matcher for the `--key` flag, without it every value matches. EncryptYAML skips the
+// structure of the config file either way.
+func keyMatcher(cmd *cli.Command) (func(path string, key string) bool, error) {
+	if cmd.String("key") == "" {
+		return func(string, string) bool { return true }, nil
+	}
+
+	re, err := regexp.Compile(cmd.String("key"))
+	if err != nil {
+		return nil, fmt.Errorf("while parsing --key: %w", err)
+	}
+
+	return func(_ string, key string) bool { return re.MatchString(key) }, nil
+}
+
+// writeSecretsFile replaces path with b, keeping its permissions.
+func writeSecretsFile(path string, b []byte) error {
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := os.WriteFile(path, b, mode); err != nil {
+		return fmt.Errorf("while writing '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+func secretsEncrypt(_ context.Context, cmd *cli.Command) error {
+	path, b, err := secretsFile(cmd)
+	if err != nil {
+		return err
+	}
+
+	_, recipients, err := secretsKeys(cmd)
+	if err != nil {
+		return err
+	}
+
+	match, err := keyMatcher(cmd)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := octosecrets.EncryptYAML(b, recipients, match)
+	if err != nil {
+		return fmt.Errorf("while encrypting '%s': %w", path, err)
+	}
+
+	return writeSecretsFile(path, encrypted)
+}
+
+func secretsDecrypt(_ context.Context, cmd *cli.Command) error {
+	path, b, err := secretsFile(cmd)
+	if err != nil {
+		return err
+	}
+
+	identities, err := octosecrets.LoadIdentities(cmd.String("age-key-file"))
+	if err != nil {
+		return err
+	}
+
+	decrypted, _, err := octosecrets.DecryptYAML(b, identities)
+	if err != nil {
+		return fmt.Errorf("while decrypting '%s': %w", path, err)
+	}
+
+	if cmd.Bool("in-place") {
+		return writeSecretsFile(path, decrypted)
+	}
+
+	//nolint:forbidigo
+	fmt.Print(string(decrypted))
+
+	return nil
+}
+
+func secretsEdit(ctx context.Context, cmd *cli.Command) error {
+	path, b, err := secretsFile(cmd)
+	if err != nil {
+		return err
+	}
+
+	identities, recipients, err := secretsKeys(cmd)
+	if err != nil {
+		return err
+	}
+
+	leaves, err := octosecrets.Leaves(b)
+	if err != nil {
+		return fmt.Errorf("while parsing '%s': %w", path, err)
+	}
+
+	decrypted, encryptedPaths, err := octosecrets.DecryptYAML(b, identities)
+	if err != nil {
+		return fmt.Errorf("while decrypting '%s': %w", path, err)
+	}
+
+	// The plain text never goes to the cache, only to a private temporary file.
+	tmpDir, err := os.MkdirTemp("", "octoctl-secrets-")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			slog.Error("Error while removing the temporary directory", "path", tmpDir, "error", err)
+		}
+	}()
+
+	tmpPath := filepath.Join(tmpDir, filepath.Base(path))
+	if err := os.WriteFile(tmpPath, decrypted, 0o600); err != nil {
+		return err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	editCmd := exec.CommandContext(ctx, editor, tmpPath) //nolint:gosec
+	editCmd.Stdin = os.Stdin
+	editCmd.Stdout = os.Stdout
+	editCmd.Stderr = os.Stderr
+
+	if err := editCmd.Run(); err != nil {
+		return fmt.Errorf("while running '%s': %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(tmpPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	keyMatch, err := keyMatcher(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Values which have been encrypted stay encrypted, fully encrypted files stay fully encrypted.
+	allEncrypted := len(leaves) > 0 && len(encryptedPaths) == len(leaves)
+	match := func(path string, key string) bool {
+		if allEncrypted || slices.Contains(encryptedPaths, path) {
+			return true
+		}
+
+		return cmd.String("key") != "" && keyMatch(path, key)
+	}
+
+	encrypted, err := octosecrets.EncryptYAML(edited, recipients, match)
+	if err != nil {
+		return fmt.Errorf("while encrypting '%s': %w", path, err)
+	}
+
+	return writeSecretsFile(path, encrypted)
+}
+
+// loadProject creates the configuration without running it, it's enough to know the project.
+func loadProject(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	logger, err := log.New(log.WithLevel(cmd.String("log-level")))
+	if err != nil {
+		return ctx, err
+	}
+
+	discovery, err := discoverConfig(cmd)
+	if err != nil {
+		logger.Error("Error while looking for configuration files", "error", err)
+		return ctx, err
+	}
+
+	logger.Debug("Using configuration files", "paths", discovery.Paths, "source", discovery.Source)
+
+	cfg, err := newConfig(logger, cmd, discovery.Paths)
+	if err != nil {
+		return ctx, err
+	}
+
+	if err := cfg.EnsureProjectID(ctx); err != nil {
+		return ctx, err
+	}
+
+	ctx = context.WithValue(ctx, configKey{}, cfg)
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
+
+	return ctx, nil
+}
+
+func secretsList(ctx context.Context, cmd *cli.Command) error {
+	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
+
+	store, err := cfg.GeneratedSecrets()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	//nolint:errcheck
+	fmt.Fprintln(w, "NAME\tTYPE\tCREATED")
+
+	for _, name := range store.List() {
+		secret, _ := store.Get(name)
+
+		//nolint:errcheck
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, secret.Type, secret.Created.Format(time.RFC3339))
+
+		if cmd.Bool("show") {
+			//nolint:errcheck
+			fmt.Fprintf(w, "\t%q\t\n", secret.Value)
+		}
+	}
+
+	return w.Flush()
+}
+
+func secretsRotate(ctx context.Context, cmd *cli.Command) error {
+	cfg := ctx.Value(configKey{}).(*octoconfig.Config) //nolint:errcheck
+	logger := ctx.Value(loggerKey{}).(log.Logger)      //nolint:errcheck
+
+	store, err := cfg.GeneratedSecrets()
+	if err != nil {
+		return err
+	}
+
+	names := cmd.Args().Slice()
+	if cmd.Bool("all") {
+		names = store.List()
+	}
+
+	if len(names) == 0 {
+		return errors.New("no secrets given, pass their names or --all")
+	}
+
+	if err := store.Rotate(names...); err != nil {
+		logger.Error("Error while rotating secrets", "error", err)
+		return err
+	}
+
+	logger.Info("Rotated secrets, restart the services to use them", "secrets", names)
+
+	return nil
+}
+
+// secretsEncryptFlags returns the flags of the commands which encrypt.
+func secretsEncryptFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "recipient",
+			Usage: "age recipient to encrypt for, defaults to the recipients of the age key",
+		},
+		&cli.StringFlag{
+			Name:  "key",
+			Usage: "Only encrypt values whose key matches this regular expression, defaults to all values",
+		},
+	}
+}
+
+// secretsCommand returns the `secrets` command.
+func secretsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "secrets",
+		Usage: "Manages encrypted values and generated secrets.",
+		Commands: []*cli.Command{
+			{
+				Name:      "encrypt",
+				Usage:     "Encrypts the values of a file in place.",
+				ArgsUsage: "file",
+				Flags:     secretsEncryptFlags(),
+				Action:    secretsEncrypt,
+			},
+			{
+				Name:      "decrypt",
+				Usage:     "Prints a file with its values decrypted.",
+				ArgsUsage: "file",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "in-place",
+						Usage: "Write the decrypted values back to the file.",
+					},
+				},
+				Action: secretsDecrypt,
+			},
+			{
+				Name:      "edit",
+				Usage:     "Opens a file decrypted in $EDITOR and encrypts it again.",
+				ArgsUsage: "file",
+				Flags:     secretsEncryptFlags(),
+				Action:    secretsEdit,
+			},
+			{
+				Name:   "list",
+				Usage:  "Lists the generated secrets of the project.",
+				Before: loadProject,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "show",
+						Usage: "Show the values.",
+					},
+				},
+				Action: secretsList,
+			},
+			{
+				Name:      "rotate",
+				Usage:     "Generates new values for generated secrets.",
+				ArgsUsage: "[name...]",
+				Before:    loadProject,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Rotate all generated secrets.",
+					},
+				},
+				Action: secretsRotate,
+			},
+		},
+	}
+}
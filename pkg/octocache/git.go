@@ -0,0 +1,253 @@
+package octocache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	orbconfig "github.com/go-orb/go-orb/config"
+)
+
+// gitSchemePrefix is the prefix of git URL schemes like `git+https` and `git+ssh`.
+const gitSchemePrefix = "git+"
+
+// gitHEAD is the reference used when a git URL has no `ref`.
+const gitHEAD = "HEAD"
+
+// ErrGitURL happens on git URLs without a file path.
+var ErrGitURL = errors.New("invalid git URL")
+
+// gitCommits memoizes the resolved commit of each repository and reference, so all files of a repository
+// get read from the same commit during a run. gitLocks holds a lock per cache path, so each repository
+// gets cloned and fetched once while other repositories are fetched at the same time. gitCommitsMu guards
+// both maps.
+//
+//nolint:gochecknoglobals
+var (
+	gitCommits   = map[string]string{}
+	gitLocks     = map[string]*sync.Mutex{}
+	gitCommitsMu sync.Mutex
+)
+
+// gitLock returns the lock of the repository cached at cachePath.
+func gitLock(cachePath string) *sync.Mutex {
+	gitCommitsMu.Lock()
+	defer gitCommitsMu.Unlock()
+
+	mu, ok := gitLocks[cachePath]
+	if !ok {
+		mu = &sync.Mutex{}
+		gitLocks[cachePath] = mu
+	}
+
+	return mu
+}
+
+// gitCommit returns the memoized commit of key.
+func gitCommit(key string) (string, bool) {
+	gitCommitsMu.Lock()
+	defer gitCommitsMu.Unlock()
+
+	commit, ok := gitCommits[key]
+
+	return commit, ok
+}
+
+// setGitCommit memoizes the commit of key.
+func setGitCommit(key string, commit string) {
+	gitCommitsMu.Lock()
+	defer gitCommitsMu.Unlock()
+
+	gitCommits[key] = commit
+}
+
+// GitURL represents a file in a git repository, given as `git+https://host/repo.git//path/file.yaml?ref=v1.2`.
+type GitURL struct {
+	// Repo is the URL of the repository without the `git+` prefix.
+	Repo string
+	// File is the path of the file inside the repository.
+	File string
+	// Ref is the branch, tag or commit, it defaults to HEAD.
+	Ref string
+}
+
+// IsGitURL returns true if the scheme of u starts with `git+`.
+func IsGitURL(u *url.URL) bool {
+	return strings.HasPrefix(u.Scheme, gitSchemePrefix)
+}
+
+// ParseGitURL splits a git URL into the repository, the file inside it and the reference.
+func ParseGitURL(u *url.URL) (*GitURL, error) {
+	if !IsGitURL(u) {
+		return nil, fmt.Errorf("%w '%s': the scheme has to start with '%s'", ErrGitURL, u.String(), gitSchemePrefix)
+	}
+
+	repoPath, file, ok := strings.Cut(u.Path, "//")
+	if !ok || file == "" {
+		return nil, fmt.Errorf("%w '%s': expected 'repo.git//path/to/file'", ErrGitURL, u.String())
+	}
+
+	repo := *u
+	repo.Scheme = strings.TrimPrefix(u.Scheme, gitSchemePrefix)
+	repo.Path = repoPath
+	repo.RawPath = ""
+	repo.RawQuery = ""
+	repo.Fragment = ""
+
+	ref := u.Query().Get("ref")
+	if ref == "" {
+		ref = gitHEAD
+	}
+
+	return &GitURL{Repo: repo.String(), File: path.Clean(file), Ref: ref}, nil
+}
+
+// cachedGitURL reads the file of a git URL from the commit its reference points to, the commit
+// gets pinned in the lock.
+func cachedGitURL(ctx context.Context, projectID string, u *orbconfig.URL, cacheType string) (*orbconfig.URL, error) {
+	gitURL, err := ParseGitURL(u.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	r, commit, err := resolveGit(ctx, projectID, gitURL)
+	if err != nil {
+		return nil, fmt.Errorf("while resolving '%s' of '%s': %w", gitURL.Ref, gitURL.Repo, err)
+	}
+
+	commitObj, err := r.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commitObj.File(gitURL.File)
+	if err != nil {
+		return nil, fmt.Errorf("while reading '%s' at commit %s of '%s': %w", gitURL.File, commit, gitURL.Repo, err)
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("while reading '%s' at commit %s of '%s': %w", gitURL.File, commit, gitURL.Repo, err)
+	}
+
+	sha256sum := sha256.Sum256([]byte(u.String() + "@" + commit))
+
+	cachedPath, err := Path(projectID, cacheType, hex.EncodeToString(sha256sum[:16])+filepath.Ext(gitURL.File))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFile(cachedPath, strings.NewReader(contents)); err != nil {
+		return nil, fmt.Errorf("while writing file '%s': %w", cachedPath, err)
+	}
+
+	if err := checkLock(LockFromContext(ctx), u, cachedPath); err != nil {
+		return nil, err
+	}
+
+	return orbconfig.NewURL("file://" + cachedPath)
+}
+
+// resolveGit returns the cached mirror of the repository and the commit of the reference, it clones or
+// fetches the repository once per run. Clones are shared by all includes of a repository.
+func resolveGit(ctx context.Context, projectID string, gitURL *GitURL) (*git.Repository, string, error) {
+	sha256sum := sha256.Sum256([]byte(gitURL.Repo))
+
+	cachePath, err := Path(projectID, "git", hex.EncodeToString(sha256sum[:16]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	mu := gitLock(cachePath)
+	mu.Lock()
+
+	defer mu.Unlock()
+
+	key := cachePath + "#" + gitURL.Ref
+
+	r, err := git.PlainOpen(cachePath)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, git.ErrRepositoryNotExists):
+		slog.Debug("Cloning git repository", "repository", gitURL.Repo, "cachePath", cachePath)
+
+		r, err = git.PlainCloneContext(ctx, cachePath, true, &git.CloneOptions{
+			URL:    gitURL.Repo,
+			Mirror: true,
+			Tags:   git.AllTags,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("while cloning: %w", err)
+		}
+
+		// The fresh clone is up to date.
+		setGitCommit(cachePath, "")
+	default:
+		return nil, "", err
+	}
+
+	if commit, ok := gitCommit(key); ok {
+		return r, commit, nil
+	}
+
+	lock := LockFromContext(ctx)
+
+	commit := ""
+	if lock != nil {
+		commit = lock.Commit(gitURL.Repo, gitURL.Ref)
+	}
+
+	_, fetched := gitCommit(cachePath)
+
+	// Pinned commits only need a fetch when they're unknown.
+	if commit != "" {
+		if _, err := r.CommitObject(plumbing.NewHash(commit)); err == nil {
+			fetched = true
+		}
+	}
+
+	if !fetched {
+		slog.Debug("Fetching git repository", "repository", gitURL.Repo, "cachePath", cachePath)
+
+		if err := r.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{"+refs/*:refs/*"},
+			Tags:     git.AllTags,
+			Force:    true,
+		}); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil, "", fmt.Errorf("while fetching: %w", err)
+		}
+
+		setGitCommit(cachePath, "")
+	}
+
+	if commit == "" {
+		hash, err := r.ResolveRevision(plumbing.Revision(gitURL.Ref))
+		if err != nil {
+			return nil, "", err
+		}
+
+		commit = hash.String()
+	}
+
+	slog.Debug("Resolved git reference", "repository", gitURL.Repo, "ref", gitURL.Ref, "commit", commit)
+
+	if lock != nil {
+		lock.SetCommit(gitURL.Repo, gitURL.Ref, commit)
+	}
+
+	setGitCommit(key, commit)
+
+	return r, commit, nil
+}
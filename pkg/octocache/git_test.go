@@ -0,0 +1,152 @@
+package octocache
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitURL(t *testing.T) {
+	u, err := url.Parse("git+ssh://git@example.com/org/repo.git//path/to/file.yaml?ref=v1.2")
+	require.NoError(t, err)
+
+	gitURL, err := ParseGitURL(u)
+	require.NoError(t, err)
+	require.Equal(t, "ssh://git@example.com/org/repo.git", gitURL.Repo)
+	require.Equal(t, "path/to/file.yaml", gitURL.File)
+	require.Equal(t, "v1.2", gitURL.Ref)
+
+	u, err = url.Parse("git+https://example.com/repo.git//file.yaml")
+	require.NoError(t, err)
+
+	gitURL, err = ParseGitURL(u)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/repo.git", gitURL.Repo)
+	require.Equal(t, gitHEAD, gitURL.Ref)
+
+	u, err = url.Parse("git+https://example.com/repo.git")
+	require.NoError(t, err)
+
+	_, err = ParseGitURL(u)
+	require.ErrorIs(t, err, ErrGitURL)
+}
+
+// commitFile writes a file to the repository at dir and commits it.
+func commitFile(t *testing.T, r *git.Repository, dir string, name string, content string) string {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	_, err = w.Add(name)
+	require.NoError(t, err)
+
+	hash, err := w.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return hash.String()
+}
+
+func TestCachedGitURL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+
+	r, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	first := commitFile(t, r, dir, "configs/app.yaml", "version: 1\n")
+
+	lock := NewLock(filepath.Join(t.TempDir(), LockFileName))
+	ctx := WithLock(context.Background(), lock)
+
+	u, err := config.NewURL("git+file://" + dir + "//configs/app.yaml")
+	require.NoError(t, err)
+
+	cached, err := CachedURL(ctx, "git-test", u, nil, "configs", true)
+	require.NoError(t, err)
+	require.Equal(t, "file", cached.Scheme)
+
+	b, err := os.ReadFile(cached.Path)
+	require.NoError(t, err)
+	require.Equal(t, "version: 1\n", string(b))
+	require.Equal(t, first, lock.Commit("file://"+dir, gitHEAD))
+
+	// Later files of the run are read from the same commit.
+	commitFile(t, r, dir, "configs/app.yaml", "version: 2\n")
+
+	cached, err = CachedURL(ctx, "git-test", u, nil, "configs", true)
+	require.NoError(t, err)
+
+	b, err = os.ReadFile(cached.Path)
+	require.NoError(t, err)
+	require.Equal(t, "version: 1\n", string(b))
+
+	u, err = config.NewURL("git+file://" + dir + "//configs/missing.yaml")
+	require.NoError(t, err)
+
+	_, err = CachedURL(ctx, "git-test", u, nil, "configs", true)
+	require.Error(t, err)
+}
+
+func TestCachedGitURLConcurrent(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	lock := NewLock(filepath.Join(t.TempDir(), LockFileName))
+	ctx := WithLock(context.Background(), lock)
+
+	dirs := []string{t.TempDir(), t.TempDir()}
+	commits := make([]string, len(dirs))
+
+	for idx, dir := range dirs {
+		r, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+
+		commits[idx] = commitFile(t, r, dir, "app.yaml", "repo: "+strconv.Itoa(idx)+"\n")
+	}
+
+	// Reads of the same repository share one clone, different repositories get cloned at the same time.
+	wg := sync.WaitGroup{}
+	errs := make([]error, 8)
+
+	for idx := range errs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			u, err := config.NewURL("git+file://" + dirs[idx%len(dirs)] + "//app.yaml")
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+
+			_, errs[idx] = CachedURL(ctx, "git-test", u, nil, "configs", true)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	for idx, dir := range dirs {
+		require.Equal(t, commits[idx], lock.Commit("file://"+dir, gitHEAD))
+	}
+}
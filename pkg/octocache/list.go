@@ -0,0 +1,141 @@
+package octocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	orbconfig "github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octooci"
+)
+
+// ErrNotListable happens when listing a URL whose source can't be listed, like HTTP.
+var ErrNotListable = errors.New("URL can't be listed")
+
+// IsPattern returns true if p contains glob meta characters.
+func IsPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// ListURL returns the regular files matching the glob in the path of u in lexical order, local files, git
+// URLs and OCI URLs can be listed. For git and OCI URLs the glob applies to the path inside the repository
+// or artifact.
+func ListURL(ctx context.Context, projectID string, u *orbconfig.URL) ([]*orbconfig.URL, error) {
+	var (
+		files []string
+		err   error
+	)
+
+	switch {
+	case u.Scheme == "file":
+		files, err = listDir("/", strings.TrimPrefix(path.Clean(u.Path), "/"))
+	case IsGitURL(u.URL):
+		files, err = listGit(ctx, projectID, u)
+	case octooci.IsOCIURL(u.URL):
+		files, err = listOCI(ctx, projectID, u)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrNotListable, u.String())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("while listing '%s': %w", u.String(), err)
+	}
+
+	slices.Sort(files)
+
+	result := make([]*orbconfig.URL, 0, len(files))
+
+	for _, file := range files {
+		listed, err := u.Copy()
+		if err != nil {
+			return nil, err
+		}
+
+		listed.RawPath = ""
+
+		if repo, _, ok := strings.Cut(u.Path, "//"); ok && u.Scheme != "file" {
+			listed.Path = repo + "//" + file
+		} else {
+			listed.Path = "/" + file
+		}
+
+		result = append(result, listed)
+	}
+
+	return result, nil
+}
+
+// listDir returns the slash separated paths of the regular files below root matching pattern.
+func listDir(root string, pattern string) ([]string, error) {
+	matches, err := fs.Glob(os.DirFS(root), pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(matches, func(match string) bool {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(match)))
+		return err != nil || !info.Mode().IsRegular()
+	}), nil
+}
+
+// listGit returns the files of the commit the git URL points to matching its path.
+func listGit(ctx context.Context, projectID string, u *orbconfig.URL) ([]string, error) {
+	gitURL, err := ParseGitURL(u.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := path.Match(gitURL.File, ""); err != nil {
+		return nil, err
+	}
+
+	r, commit, err := resolveGit(ctx, projectID, gitURL)
+	if err != nil {
+		return nil, err
+	}
+
+	commitObj, err := r.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := commitObj.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+
+	err = files.ForEach(func(file *object.File) error {
+		if matched, _ := path.Match(gitURL.File, file.Name); matched && file.Mode.IsFile() {
+			result = append(result, file.Name)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// listOCI returns the files of the artifact the OCI URL points to matching its path.
+func listOCI(ctx context.Context, projectID string, u *orbconfig.URL) ([]string, error) {
+	ref, err := octooci.ParseReference(u.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := unpackOCI(ctx, projectID, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return listDir(dir, ref.File)
+}
@@ -0,0 +1,67 @@
+package octocache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func listed(t *testing.T, raw string) []string {
+	t.Helper()
+
+	u, err := config.NewURL(raw)
+	require.NoError(t, err)
+
+	urls, err := ListURL(t.Context(), "list-test", u)
+	require.NoError(t, err)
+
+	result := []string{}
+	for _, u := range urls {
+		result = append(result, u.String())
+	}
+
+	return result
+}
+
+func TestListURL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+
+	r, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	commitFile(t, r, dir, "services/b.yaml", "b: 1\n")
+	commitFile(t, r, dir, "services/a.yaml", "a: 1\n")
+	commitFile(t, r, dir, "services/nested/c.yaml", "c: 1\n")
+
+	require.Equal(t, []string{
+		"file://" + filepath.Join(dir, "services", "a.yaml"),
+		"file://" + filepath.Join(dir, "services", "b.yaml"),
+	}, listed(t, "file://"+dir+"/services/*.yaml"))
+
+	require.Equal(t, []string{
+		"git+file://" + dir + "//services/a.yaml?ref=HEAD",
+		"git+file://" + dir + "//services/b.yaml?ref=HEAD",
+	}, listed(t, "git+file://"+dir+"//services/*.yaml?ref=HEAD"))
+
+	host := serveArtifact(t, "org/penpot", "1.2.0", map[string]string{
+		"conf.d/20-b.yaml": "b: 1\n",
+		"conf.d/10-a.yaml": "a: 1\n",
+	})
+
+	require.Equal(t, []string{
+		"oci://" + host + "/org/penpot:1.2.0//conf.d/10-a.yaml",
+		"oci://" + host + "/org/penpot:1.2.0//conf.d/20-b.yaml",
+	}, listed(t, "oci://"+host+"/org/penpot:1.2.0//conf.d/*"))
+
+	u, err := config.NewURL("https://example.com/*.yaml")
+	require.NoError(t, err)
+
+	_, err = ListURL(t.Context(), "list-test", u)
+	require.ErrorIs(t, err, ErrNotListable)
+
+}
@@ -0,0 +1,331 @@
+package octocache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// LockFileName is the name of the lockfile written next to the first config file.
+const LockFileName = "octoctl.lock"
+
+// lockVersion is the version of the lockfile format.
+const lockVersion = 1
+
+// ErrLockMismatch happens when fetched content doesn't match the lockfile.
+var ErrLockMismatch = errors.New("content doesn't match the lockfile")
+
+// LockEntry represents a single pinned URL.
+type LockEntry struct {
+	SHA256 string `json:"sha256"`
+}
+
+// LockGit represents a pinned git reference.
+type LockGit struct {
+	URL    string `json:"url"`
+	Ref    string `json:"ref"`
+	Commit string `json:"commit"`
+}
+
+// LockVersion represents the version pinned for a version constraint of a versions index.
+type LockVersion struct {
+	Index      string `json:"index"`
+	Constraint string `json:"constraint"`
+	Version    string `json:"version"`
+	URL        string `json:"url"`
+}
+
+// Lock pins every fetched URL to its SHA-256 sum, every git reference to a commit and every versioned include
+// to a version.
+type Lock struct {
+	Version  int                    `json:"version"`
+	Files    map[string]LockEntry   `json:"files"`
+	Git      map[string]LockGit     `json:"git,omitempty"`
+	Versions map[string]LockVersion `json:"versions,omitempty"`
+
+	path   string
+	update bool
+	pin    bool
+	dirty  bool
+	seen   map[string]struct{}
+
+	mu sync.Mutex
+}
+
+type lockKey struct{}
+
+// WithLock returns a context which carries the lock, CachedURL verifies against it.
+func WithLock(ctx context.Context, lock *Lock) context.Context {
+	return context.WithValue(ctx, lockKey{}, lock)
+}
+
+// LockFromContext returns the lock from the context or nil.
+func LockFromContext(ctx context.Context) *Lock {
+	lock, ok := ctx.Value(lockKey{}).(*Lock)
+	if !ok {
+		return nil
+	}
+
+	return lock
+}
+
+// NewLock creates an empty lock which will be written to path, it pins everything that gets fetched.
+func NewLock(path string) *Lock {
+	return &Lock{
+		Version:  lockVersion,
+		Files:    map[string]LockEntry{},
+		Git:      map[string]LockGit{},
+		Versions: map[string]LockVersion{},
+		path:     path,
+		pin:      true,
+		dirty:    true,
+		seen:     map[string]struct{}{},
+	}
+}
+
+// ReadLock reads the lockfile at path, it returns nil if there is none.
+func ReadLock(path string) (*Lock, error) {
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil //nolint:nilnil
+		}
+
+		return nil, fmt.Errorf("while reading lockfile '%s': %w", path, err)
+	}
+
+	lock := NewLock(path)
+	lock.pin = false
+	lock.dirty = false
+
+	if err := json.Unmarshal(b, lock); err != nil {
+		return nil, fmt.Errorf("while parsing lockfile '%s': %w", path, err)
+	}
+
+	if lock.Version != lockVersion {
+		return nil, fmt.Errorf("lockfile '%s' has unsupported version %d", path, lock.Version)
+	}
+
+	if lock.Files == nil {
+		lock.Files = map[string]LockEntry{}
+	}
+
+	if lock.Git == nil {
+		lock.Git = map[string]LockGit{}
+	}
+
+	if lock.Versions == nil {
+		lock.Versions = map[string]LockVersion{}
+	}
+
+	return lock, nil
+}
+
+// Path returns the path of the lockfile.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// SetUpdate makes the lock replace pinned entries instead of verifying them.
+func (l *Lock) SetUpdate(update bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.update = update
+}
+
+// SetPin makes the lock add entries for everything which isn't pinned yet, without it unpinned entries only
+// get a warning and the lockfile stays as it is.
+func (l *Lock) SetPin(pin bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pin = pin
+}
+
+// Updating returns true if the lock replaces pinned entries.
+func (l *Lock) Updating() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.update
+}
+
+// refresh returns true if url has to be fetched again while updating the lock.
+func (l *Lock) refresh(url string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.update {
+		return false
+	}
+
+	_, ok := l.seen[url]
+
+	return !ok
+}
+
+// Check verifies the SHA-256 sum of url, unknown URLs get pinned if the lock pins.
+func (l *Lock) Check(url string, sha256sum string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, seen := l.seen[url]
+	l.seen[url] = struct{}{}
+
+	entry, ok := l.Files[url]
+	if ok && entry.SHA256 == sha256sum {
+		return nil
+	}
+
+	if ok && !l.update {
+		return fmt.Errorf("%w: '%s' expected sha256 %s, got %s", ErrLockMismatch, url, entry.SHA256, sha256sum)
+	}
+
+	if !ok && !l.pin {
+		if !seen {
+			slog.Warn("URL isn't pinned in the lockfile, run 'octoctl lock' to pin it", "url", url, "lockfile", l.path)
+		}
+
+		return nil
+	}
+
+	l.Files[url] = LockEntry{SHA256: sha256sum}
+	l.dirty = true
+
+	return nil
+}
+
+// gitKey returns the map key for a git reference.
+func gitKey(url string, ref string) string {
+	return url + "#" + ref
+}
+
+// Commit returns the pinned commit for the git url and ref, empty if there is none or the lock gets updated.
+func (l *Lock) Commit(url string, ref string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.update {
+		return ""
+	}
+
+	return l.Git[gitKey(url, ref)].Commit
+}
+
+// SetCommit pins the git url and ref to commit if the lock pins.
+func (l *Lock) SetCommit(url string, ref string, commit string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := gitKey(url, ref)
+
+	pinned, ok := l.Git[key]
+	if pinned.Commit == commit {
+		return
+	}
+
+	if !ok && !l.pin {
+		slog.Warn("Git reference isn't pinned in the lockfile, run 'octoctl lock' to pin it",
+			"url", url, "ref", ref, "lockfile", l.path)
+
+		return
+	}
+
+	l.Git[key] = LockGit{URL: url, Ref: ref, Commit: commit}
+	l.dirty = true
+}
+
+// versionKey returns the map key for a version constraint of a versions index.
+func versionKey(index string, constraint string) string {
+	return index + "#" + constraint
+}
+
+// PinnedVersion returns the version pinned for the constraint of the versions index, there is none while the
+// lock gets updated.
+func (l *Lock) PinnedVersion(index string, constraint string) (LockVersion, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.update {
+		return LockVersion{}, false
+	}
+
+	version, ok := l.Versions[versionKey(index, constraint)]
+
+	return version, ok
+}
+
+// SetVersion pins the version chosen for a version constraint if the lock pins.
+func (l *Lock) SetVersion(version LockVersion) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := versionKey(version.Index, version.Constraint)
+
+	pinned, ok := l.Versions[key]
+	if pinned == version {
+		return
+	}
+
+	if !ok && !l.pin {
+		slog.Warn("Version isn't pinned in the lockfile, run 'octoctl lock' to pin it",
+			"index", version.Index, "constraint", version.Constraint, "lockfile", l.path)
+
+		return
+	}
+
+	l.Versions[key] = version
+	l.dirty = true
+}
+
+// Save writes the lockfile if it has changed.
+func (l *Lock) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("while marshaling lockfile: %w", err)
+	}
+
+	if err := os.WriteFile(l.path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("while writing lockfile '%s': %w", l.path, err)
+	}
+
+	l.dirty = false
+
+	return nil
+}
+
+// fileSha256Sum returns the hex encoded SHA-256 sum of a file.
+func fileSha256Sum(path string) (string, error) {
+	fp, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if err := fp.Close(); err != nil {
+			slog.Error("Error while closing the file", "file", path, "error", err)
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fp); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
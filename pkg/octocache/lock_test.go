@@ -0,0 +1,97 @@
+package octocache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockSaveRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+
+	lock := NewLock(path)
+	require.NoError(t, lock.Check("https://example.com/a.yaml", "aaaa"))
+	lock.SetCommit("https://example.com/repo.git", "refs/heads/main", "1234")
+	require.NoError(t, lock.Save())
+
+	read, err := ReadLock(path)
+	require.NoError(t, err)
+	require.Equal(t, "aaaa", read.Files["https://example.com/a.yaml"].SHA256)
+	require.Equal(t, "1234", read.Commit("https://example.com/repo.git", "refs/heads/main"))
+
+	require.ErrorIs(t, read.Check("https://example.com/a.yaml", "bbbb"), ErrLockMismatch)
+
+	read.SetUpdate(true)
+	require.NoError(t, read.Check("https://example.com/a.yaml", "bbbb"))
+	require.Empty(t, read.Commit("https://example.com/repo.git", "refs/heads/main"))
+}
+
+func TestLockReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+
+	lock := NewLock(path)
+	require.NoError(t, lock.Check("https://example.com/a.yaml", "aaaa"))
+	require.NoError(t, lock.Save())
+
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Runs other than `octoctl lock` verify the pinned entries but don't add new ones.
+	read, err := ReadLock(path)
+	require.NoError(t, err)
+	require.NoError(t, read.Check("https://example.com/a.yaml", "aaaa"))
+	require.NoError(t, read.Check("https://example.com/b.yaml", "bbbb"))
+	read.SetCommit("https://example.com/repo.git", "refs/heads/main", "1234")
+	read.SetVersion(LockVersion{Index: "https://example.com/versions.yaml", Constraint: "^1", Version: "1.2.0"})
+	require.NoError(t, read.Save())
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(before), string(after))
+
+	read.SetPin(true)
+	require.NoError(t, read.Check("https://example.com/b.yaml", "bbbb"))
+	require.NoError(t, read.Save())
+
+	read, err = ReadLock(path)
+	require.NoError(t, err)
+	require.Equal(t, "bbbb", read.Files["https://example.com/b.yaml"].SHA256)
+}
+
+func TestReadLockMissing(t *testing.T) {
+	lock, err := ReadLock(filepath.Join(t.TempDir(), LockFileName))
+	require.NoError(t, err)
+	require.Nil(t, lock)
+}
+
+func TestCachedURLLockDrift(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	content := "name: first\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+
+	url, err := config.NewURL(server.URL + "/config.yaml")
+	require.NoError(t, err)
+
+	lock := NewLock(filepath.Join(t.TempDir(), LockFileName))
+	ctx := WithLock(t.Context(), lock)
+
+	_, err = CachedURL(ctx, "test", url, nil, "configs", true)
+	require.NoError(t, err)
+	require.Contains(t, lock.Files, url.String())
+
+	// The remote serves something else now.
+	content = "name: second\n"
+	require.NoError(t, ClearCache("test"))
+
+	_, err = CachedURL(ctx, "test", url, nil, "configs", true)
+	require.ErrorIs(t, err, ErrLockMismatch)
+}
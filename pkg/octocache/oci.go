@@ -0,0 +1,132 @@
+package octocache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	orbconfig "github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octooci"
+)
+
+// ociDigests memoizes the manifest digest of each OCI reference, so all files of an artifact get read from
+// the same digest during a run.
+//
+//nolint:gochecknoglobals
+var (
+	ociDigests   = map[string]string{}
+	ociDigestsMu sync.Mutex
+)
+
+// cachedOCIURL unpacks the artifact of an `oci://` URL into the cache and returns the file inside it.
+// Artifacts are cached by their digest, a tag gets resolved once per run.
+func cachedOCIURL(ctx context.Context, projectID string, u *orbconfig.URL) (*orbconfig.URL, error) {
+	ref, err := octooci.ParseReference(u.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.File == "" {
+		return nil, fmt.Errorf("%w '%s': expected 'oci://registry/repository:tag//path/to/file'", octooci.ErrReference, u.String())
+	}
+
+	dir, err := unpackOCI(ctx, projectID, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedPath, err := safePath(dir, ref.File)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(cachedPath); err != nil {
+		return nil, fmt.Errorf("while reading '%s' of %s: %w", ref.File, ref.String(), err)
+	}
+
+	if err := checkLock(LockFromContext(ctx), u, cachedPath); err != nil {
+		return nil, err
+	}
+
+	return orbconfig.NewURL("file://" + cachedPath)
+}
+
+// unpackOCI returns the directory the artifact of ref is unpacked in, it pulls the artifact if needed.
+func unpackOCI(ctx context.Context, projectID string, ref *octooci.Reference) (string, error) {
+	ociDigestsMu.Lock()
+	defer ociDigestsMu.Unlock()
+
+	client := octooci.NewClient(octooci.WithEnvironment())
+
+	digest, ok := ociDigests[ref.String()]
+
+	var manifest *octooci.Manifest
+
+	if !ok {
+		var err error
+
+		manifest, digest, err = client.Resolve(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+
+		slog.Debug("Resolved OCI reference", "reference", ref.String(), "digest", digest)
+
+		ociDigests[ref.String()] = digest
+	}
+
+	dir, err := Path(projectID, "oci", strings.TrimPrefix(digest, "sha256:"))
+	if err != nil {
+		return "", err
+	}
+
+	// Artifacts are immutable, an unpacked digest never changes.
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	pinned := *ref
+	pinned.Reference = digest
+
+	if manifest == nil {
+		if manifest, _, err = client.Resolve(ctx, &pinned); err != nil {
+			return "", err
+		}
+	}
+
+	// Unpack next to the final directory and move it in place, partial artifacts never end up in the cache.
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), "unpack-")
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			slog.Error("Error while removing the temporary directory", "dir", tmpDir, "error", err)
+		}
+	}()
+
+	if err := client.Unpack(ctx, &pinned, manifest, tmpDir); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// safePath joins the slash separated file to dir, it fails if file points outside of dir.
+func safePath(dir string, file string) (string, error) {
+	joined := filepath.Join(dir, filepath.FromSlash(file))
+	if rel, err := filepath.Rel(dir, joined); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: '%s'", octooci.ErrUnsafePath, file)
+	}
+
+	return joined, nil
+}
@@ -0,0 +1,116 @@
+package octocache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octooci"
+	"github.com/stretchr/testify/require"
+)
+
+func testDigest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// serveArtifact serves a read-only registry with a single artifact containing files.
+func serveArtifact(t *testing.T, repository string, tag string, files map[string]string) string {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+
+	layer := buf.Bytes()
+
+	manifest, err := json.Marshal(octooci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     octooci.MediaTypeManifest,
+		ArtifactType:  octooci.ArtifactTypeChart,
+		Config:        octooci.Descriptor{MediaType: octooci.MediaTypeEmpty, Digest: testDigest([]byte("{}")), Size: 2},
+		Layers: []octooci.Descriptor{{
+			MediaType: octooci.MediaTypeLayerTar,
+			Digest:    testDigest(layer),
+			Size:      int64(len(layer)),
+		}},
+	})
+	require.NoError(t, err)
+
+	content := map[string][]byte{
+		"/v2/" + repository + "/manifests/" + tag:                  manifest,
+		"/v2/" + repository + "/manifests/" + testDigest(manifest): manifest,
+		"/v2/" + repository + "/blobs/" + testDigest(layer):        layer,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, ok := content[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(b) //nolint:errcheck
+	}))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestCachedOCIURL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	host := serveArtifact(t, "org/penpot", "1.2.0", map[string]string{
+		"config/all.yaml": "include:\n  - url: ../shared/base.yaml\n",
+	})
+
+	u, err := config.NewURL("oci://" + host + "/org/penpot:1.2.0//config/all.yaml")
+	require.NoError(t, err)
+
+	cached, err := CachedURL(context.Background(), "oci-test", u, nil, "configs", true)
+	require.NoError(t, err)
+	require.Equal(t, "file", cached.Scheme)
+
+	b, err := os.ReadFile(cached.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), "../shared/base.yaml")
+
+	dir, err := Path("oci-test", "oci")
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	u, err = config.NewURL("oci://" + host + "/org/penpot:1.2.0//config/missing.yaml")
+	require.NoError(t, err)
+
+	_, err = CachedURL(context.Background(), "oci-test", u, nil, "configs", true)
+	require.Error(t, err)
+
+	u, err = config.NewURL("oci://" + host + "/org/penpot:1.2.0//../../escape.yaml")
+	require.NoError(t, err)
+
+	_, err = CachedURL(context.Background(), "oci-test", u, nil, "configs", true)
+	require.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "..", "escape.yaml"))
+	require.Error(t, err)
+}
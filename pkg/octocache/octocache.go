@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,22 +15,11 @@ import (
 	"strings"
 
 	"github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octooci"
 )
 
 // downloadFile downloads a file from a URL to a local path.
 func downloadFile(ctx context.Context, filepath string, myURL *url.URL) (err error) {
-	// Create the file.
-	out, err := os.Create(filepath) //nolint:gosec
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		if err := out.Close(); err != nil {
-			slog.Error("Error while closing the file", "file", filepath, "error", err)
-		}
-	}()
-
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, myURL.String(), nil)
 	if err != nil {
 		return err
@@ -55,12 +45,33 @@ func downloadFile(ctx context.Context, filepath string, myURL *url.URL) (err err
 	}
 
 	// Write the file.
-	_, err = io.Copy(out, resp.Body)
+	return writeFile(filepath, resp.Body)
+}
+
+// writeFile writes the content of r to a temporary file next to path and moves it in place, concurrent
+// readers never see a partial file.
+func writeFile(path string, r io.Reader) error {
+	out, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
 	if err != nil {
 		return err
 	}
 
-	return nil
+	defer func() {
+		if err := os.Remove(out.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Error while removing the temporary file", "file", out.Name(), "error", err)
+		}
+	}()
+
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close() //nolint:errcheck
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), path)
 }
 
 // checkSha256Sum verifies that the file matches the SHA256 checksum in the checksum file.
@@ -138,11 +149,38 @@ func CachedURL(
 	cacheType string,
 	shaFile bool,
 ) (*config.URL, error) {
+	return cachedURL(ctx, projectID, url, sha256Url, cacheType, shaFile, false)
+}
+
+// FetchURL fetches a URL whose content changes over time, like a versions index. HTTP URLs get downloaded on
+// every call, git references and OCI tags get resolved once per run. The content isn't pinned by the lock.
+func FetchURL(ctx context.Context, projectID string, url *config.URL, cacheType string) (*config.URL, error) {
+	return cachedURL(WithLock(ctx, nil), projectID, url, nil, cacheType, true, true)
+}
+
+// cachedURL implements CachedURL, with refresh an HTTP URL gets downloaded even if it's in the cache.
+func cachedURL(
+	ctx context.Context,
+	projectID string,
+	url *config.URL,
+	sha256Url *config.URL,
+	cacheType string,
+	shaFile bool,
+	refresh bool,
+) (*config.URL, error) {
 
 	if url.Scheme == "file" {
 		return url, nil
 	}
 
+	if IsGitURL(url.URL) {
+		return cachedGitURL(ctx, projectID, url, cacheType)
+	}
+
+	if octooci.IsOCIURL(url.URL) {
+		return cachedOCIURL(ctx, projectID, url)
+	}
+
 	var (
 		err        error
 		cachedPath string
@@ -163,8 +201,14 @@ func CachedURL(
 		}
 	}
 
+	lock := LockFromContext(ctx)
+
 	// Check and return if the file already exists.
-	if _, err := os.Stat(cachedPath); err == nil {
+	if _, err := os.Stat(cachedPath); err == nil && !refresh && (lock == nil || !lock.refresh(url.String())) {
+		if err := checkLock(lock, url, cachedPath); err != nil {
+			return nil, err
+		}
+
 		return config.NewURL("file://" + cachedPath)
 	}
 
@@ -185,5 +229,31 @@ func CachedURL(
 		}
 	}
 
+	if err := checkLock(lock, url, cachedPath); err != nil {
+		return nil, err
+	}
+
 	return config.NewURL("file://" + cachedPath)
 }
+
+// checkLock verifies the cached file against the lock, drifted files get removed from the cache.
+func checkLock(lock *Lock, url *config.URL, cachedPath string) error {
+	if lock == nil {
+		return nil
+	}
+
+	sum, err := fileSha256Sum(cachedPath)
+	if err != nil {
+		return fmt.Errorf("while hashing '%s': %w", cachedPath, err)
+	}
+
+	if err := lock.Check(url.String(), sum); err != nil {
+		if err := os.Remove(cachedPath); err != nil {
+			slog.Error("Error while removing the drifted file", "file", cachedPath, "error", err)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package octoconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-orb/go-orb/codecs"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Change types.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change represents a single difference between two configurations.
+type Change struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// String returns the human readable representation of the change.
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, diffValue(c.New))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, diffValue(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, diffValue(c.Old), diffValue(c.New))
+	}
+}
+
+func diffValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(b)
+}
+
+// normalize converts the data to plain JSON types, so numbers from YAML and JSON compare equal.
+func normalize(data map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// joinPath appends key to the path.
+func joinPath(path string, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		key = "[" + strconv.Quote(key) + "]"
+		return path + key
+	}
+
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func diffAny(path string, oldValue, newValue any, changes []Change) []Change {
+	switch oldTyped := oldValue.(type) {
+	case map[string]any:
+		newTyped, ok := newValue.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := slices.Collect(maps.Keys(oldTyped))
+		for key := range newTyped {
+			if _, ok := oldTyped[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			oldChild, oldOK := oldTyped[key]
+			newChild, newOK := newTyped[key]
+
+			switch {
+			case !oldOK:
+				changes = append(changes, Change{Path: joinPath(path, key), Type: ChangeAdded, New: newChild})
+			case !newOK:
+				changes = append(changes, Change{Path: joinPath(path, key), Type: ChangeRemoved, Old: oldChild})
+			default:
+				changes = diffAny(joinPath(path, key), oldChild, newChild, changes)
+			}
+		}
+
+		return changes
+	case []any:
+		newTyped, ok := newValue.([]any)
+		if !ok {
+			break
+		}
+
+		for idx := range max(len(oldTyped), len(newTyped)) {
+			itemPath := fmt.Sprintf("%s[%d]", path, idx)
+
+			switch {
+			case idx >= len(oldTyped):
+				changes = append(changes, Change{Path: itemPath, Type: ChangeAdded, New: newTyped[idx]})
+			case idx >= len(newTyped):
+				changes = append(changes, Change{Path: itemPath, Type: ChangeRemoved, Old: oldTyped[idx]})
+			default:
+				changes = diffAny(itemPath, oldTyped[idx], newTyped[idx], changes)
+			}
+		}
+
+		return changes
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		changes = append(changes, Change{Path: path, Type: ChangeChanged, Old: oldValue, New: newValue})
+	}
+
+	return changes
+}
+
+// Diff returns the path based differences from oldData to newData, sorted by path.
+func Diff(oldData, newData map[string]any) ([]Change, error) {
+	oldNormalized, err := normalize(oldData)
+	if err != nil {
+		return nil, fmt.Errorf("while normalizing the old configuration: %w", err)
+	}
+
+	newNormalized, err := normalize(newData)
+	if err != nil {
+		return nil, fmt.Errorf("while normalizing the new configuration: %w", err)
+	}
+
+	return diffAny("", oldNormalized, newNormalized, nil), nil
+}
+
+// UnifiedDiff returns a unified diff of the YAML representation of both configurations.
+func UnifiedDiff(oldName string, oldData map[string]any, newName string, newData map[string]any) (string, error) {
+	codec, err := codecs.GetMime(codecs.MimeYAML)
+	if err != nil {
+		return "", err
+	}
+
+	oldNormalized, err := normalize(oldData)
+	if err != nil {
+		return "", fmt.Errorf("while normalizing the old configuration: %w", err)
+	}
+
+	newNormalized, err := normalize(newData)
+	if err != nil {
+		return "", fmt.Errorf("while normalizing the new configuration: %w", err)
+	}
+
+	oldB, err := codec.Marshal(oldNormalized)
+	if err != nil {
+		return "", fmt.Errorf("while marshaling the old configuration: %w", err)
+	}
+
+	newB, err := codec.Marshal(newNormalized)
+	if err != nil {
+		return "", fmt.Errorf("while marshaling the new configuration: %w", err)
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(oldB)),
+		B:        difflib.SplitLines(string(newB)),
+		FromFile: oldName,
+		ToFile:   newName,
+		Context:  3,
+	})
+}
@@ -0,0 +1,44 @@
+package octoconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	oldData := map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{
+				"environment": map[string]any{"FOO": "a", "BAR": "gone"},
+				"ports":       []any{"80:80"},
+			},
+		},
+		"configs": map[string]any{"postgres": map[string]any{"port": 5432}},
+	}
+
+	newData := map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{
+				"environment": map[string]any{"FOO": "b"},
+				"ports":       []any{"80:80", "443:443"},
+			},
+		},
+		"configs": map[string]any{"postgres": map[string]any{"port": float64(5432)}},
+	}
+
+	changes, err := Diff(oldData, newData)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	require.Equal(t, `- services.penpot.environment.BAR: "gone"`, changes[0].String())
+	require.Equal(t, `~ services.penpot.environment.FOO: "a" -> "b"`, changes[1].String())
+	require.Equal(t, `+ services.penpot.ports[1]: "443:443"`, changes[2].String())
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff, err := UnifiedDiff("old", map[string]any{"name": "a"}, "new", map[string]any{"name": "b"})
+	require.NoError(t, err)
+	require.Contains(t, diff, "-name: a")
+	require.Contains(t, diff, "+name: b")
+}
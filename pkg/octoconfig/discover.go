@@ -0,0 +1,130 @@
+package octoconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-orb/go-orb/codecs"
+)
+
+// EnvConfig is the environment variable with the config files, separated by the OS path list separator.
+const EnvConfig = "OCTOCTL_CONFIG"
+
+// Sources of the config files.
+const (
+	DiscoveryFlag = "flag"
+	DiscoveryEnv  = "env"
+	DiscoveryDir  = "discovered"
+)
+
+// localSuffix is the suffix of the local override loaded next to a discovered config file.
+const localSuffix = ".local"
+
+// discoveryNames are the names of config files which get discovered, without extension.
+//
+//nolint:gochecknoglobals
+var discoveryNames = []string{"octocompose", "octoctl"}
+
+var (
+	// ErrNoConfig happens when no config file is given and none can be discovered.
+	ErrNoConfig = errors.New("no configuration files found, use --config, set " + EnvConfig + " or create octocompose.yaml")
+	// ErrAmbiguousConfig happens when a directory contains more than one config file which can be discovered.
+	ErrAmbiguousConfig = errors.New("ambiguous configuration files")
+)
+
+// Discovery represents the config files to load and how they have been found.
+type Discovery struct {
+	// Paths are the config files in the order of their priority, the last one wins.
+	Paths []string
+	// Source is one of DiscoveryFlag, DiscoveryEnv or DiscoveryDir.
+	Source string
+}
+
+// Discover returns the config files to load. The flag paths take precedence over the OCTOCTL_CONFIG environment
+// variable, without both octocompose.<ext> or octoctl.<ext> gets searched in dir and its parents. A discovered
+// file gets overridden by an octoctl.local.<ext> next to it.
+func Discover(flagPaths []string, environ []string, dir string) (*Discovery, error) {
+	if len(flagPaths) > 0 {
+		return &Discovery{Paths: flagPaths, Source: DiscoveryFlag}, nil
+	}
+
+	for _, env := range slices.Backward(environ) {
+		if value, ok := strings.CutPrefix(env, EnvConfig+"="); ok && value != "" {
+			return &Discovery{Paths: filepath.SplitList(value), Source: DiscoveryEnv}, nil
+		}
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		found, err := findConfig(dir, discoveryNames)
+		if err != nil {
+			return nil, err
+		}
+
+		if found != "" {
+			result := &Discovery{Paths: []string{found}, Source: DiscoveryDir}
+
+			local, err := findConfig(dir, []string{"octoctl" + localSuffix})
+			if err != nil {
+				return nil, err
+			}
+
+			if local != "" {
+				result.Paths = append(result.Paths, local)
+			}
+
+			return result, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNoConfig
+		}
+
+		dir = parent
+	}
+}
+
+// findConfig returns the file in dir with one of names and an extension a codec is registered for.
+func findConfig(dir string, names []string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	found := []string{}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !slices.Contains(names, strings.TrimSuffix(entry.Name(), ext)) {
+			continue
+		}
+
+		if _, err := codecs.GetExt(ext); err != nil {
+			continue
+		}
+
+		found = append(found, filepath.Join(dir, entry.Name()))
+	}
+
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	}
+
+	return "", fmt.Errorf("%w in '%s': %s", ErrAmbiguousConfig, dir, strings.Join(found, ", "))
+}
@@ -0,0 +1,47 @@
+package octoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0o700))
+
+	_, err := Discover(nil, nil, nested)
+	require.ErrorIs(t, err, ErrNoConfig)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "octocompose.yaml"), []byte("{}"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "octoctl.lock"), []byte("{}"), 0o600))
+
+	discovery, err := Discover(nil, nil, nested)
+	require.NoError(t, err)
+	require.Equal(t, DiscoveryDir, discovery.Source)
+	require.Equal(t, []string{filepath.Join(root, "octocompose.yaml")}, discovery.Paths)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "octoctl.local.yml"), []byte("{}"), 0o600))
+
+	discovery, err = Discover(nil, nil, nested)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(root, "octocompose.yaml"), filepath.Join(root, "octoctl.local.yml")}, discovery.Paths)
+
+	discovery, err = Discover(nil, []string{EnvConfig + "=a.yaml" + string(os.PathListSeparator) + "b.yaml"}, nested)
+	require.NoError(t, err)
+	require.Equal(t, DiscoveryEnv, discovery.Source)
+	require.Equal(t, []string{"a.yaml", "b.yaml"}, discovery.Paths)
+
+	discovery, err = Discover([]string{"c.yaml"}, []string{EnvConfig + "=a.yaml"}, nested)
+	require.NoError(t, err)
+	require.Equal(t, DiscoveryFlag, discovery.Source)
+	require.Equal(t, []string{"c.yaml"}, discovery.Paths)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "octoctl.json"), []byte("{}"), 0o600))
+
+	_, err = Discover(nil, nil, nested)
+	require.ErrorIs(t, err, ErrAmbiguousConfig)
+}
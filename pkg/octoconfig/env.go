@@ -0,0 +1,108 @@
+package octoconfig
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// EnvPrefix is the prefix of environment variables which override configuration keys.
+const EnvPrefix = "OCTO_"
+
+// envSeparator separates the nested keys in environment variable names.
+const envSeparator = "__"
+
+// WithEnvironment sets the environment ("KEY=value" pairs) to read `OCTO_` overrides from.
+func WithEnvironment(environ []string) Option {
+	return func(c *Config) {
+		c.environ = environ
+	}
+}
+
+// envKeys maps the name of an environment variable to the keys in data.
+// Map keys are matched case insensitive against the existing keys and lowercased if there is none,
+// numeric parts index into lists.
+func envKeys(name string, data any) ([]any, error) {
+	parts := strings.Split(strings.TrimPrefix(name, EnvPrefix), envSeparator)
+
+	keys := []any{}
+	current := data
+
+	for idx, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("empty key in '%s'", name)
+		}
+
+		index, err := strconv.Atoi(part)
+
+		list, isList := current.([]any)
+		if idx > 0 && err == nil && (isList || current == nil) {
+			keys = append(keys, index)
+
+			current = nil
+			if index >= 0 && index < len(list) {
+				current = list[index]
+			}
+
+			continue
+		}
+
+		m, _ := current.(map[string]any) //nolint:errcheck
+
+		key := strings.ToLower(part)
+		if _, ok := m[key]; !ok {
+			for _, k := range slices.Sorted(maps.Keys(m)) {
+				if strings.EqualFold(k, part) {
+					key = k
+					break
+				}
+			}
+		}
+
+		keys = append(keys, key)
+		current = m[key]
+	}
+
+	return keys, nil
+}
+
+// applyEnv applies the `OCTO_` environment variables to the merged data.
+func (c *Config) applyEnv() error {
+	mErr := &multierror.Error{}
+
+	environ := slices.Clone(c.environ)
+	slices.Sort(environ)
+
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+
+		keys, err := envKeys(name, c.Data)
+		if err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("environment variable %s: %w", name, err))
+			continue
+		}
+
+		// Environment variables don't support the `{a,b}` lists of --set, JSON like values stay strings.
+		parsed := parseScalar(value)
+
+		data, err := setIn(c.Data, keys, parsed)
+		if err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("environment variable %s: %w", name, err))
+			continue
+		}
+
+		// envKeys always starts with a map key.
+		c.Data = data.(map[string]any) //nolint:errcheck
+
+		c.provenance().set(setPathString(keys), parsed, "$"+name)
+	}
+
+	return mErr.ErrorOrNil()
+}
@@ -0,0 +1,92 @@
+package octoconfig
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvKeys(t *testing.T) {
+	data := map[string]any{
+		"octoctl":  map[string]any{"strictTemplates": false},
+		"services": map[string]any{"penpot": map[string]any{"ports": []any{"80:80"}}},
+	}
+
+	keys, err := envKeys("OCTO_OCTOCTL__STRICTTEMPLATES", data)
+	require.NoError(t, err)
+	require.Equal(t, []any{"octoctl", "strictTemplates"}, keys)
+
+	keys, err = envKeys("OCTO_SERVICES__PENPOT__PORTS__1", data)
+	require.NoError(t, err)
+	require.Equal(t, []any{"services", "penpot", "ports", 1}, keys)
+
+	keys, err = envKeys("OCTO_CONFIGS__PENPOT__PUBLIC_URI", data)
+	require.NoError(t, err)
+	require.Equal(t, []any{"configs", "penpot", "public_uri"}, keys)
+
+	_, err = envKeys("OCTO_CONFIGS____X", data)
+	require.Error(t, err)
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"configs": map[string]any{"postgres": map[string]any{"password": "secret", "port": 5432}},
+	}
+	cfg.provenance().record("", "", cfg.Data, "config.yaml", nil)
+
+	WithEnvironment([]string{
+		"HOME=/root",
+		"OCTO_CONFIGS__POSTGRES__PASSWORD=fromenv",
+		"OCTO_CONFIGS__POSTGRES__PORT=6543",
+	})(cfg)
+	WithSet(SetValue, "configs.postgres.port=7654")(cfg)
+
+	require.NoError(t, cfg.applyEnv())
+	require.NoError(t, cfg.applyOverrides())
+
+	postgres := cfg.Data["configs"].(map[string]any)["postgres"].(map[string]any)
+	require.Equal(t, "fromenv", postgres["password"])
+	require.Equal(t, 7654, postgres["port"])
+
+	entry, ok := cfg.Provenance.Get("configs.postgres.password")
+	require.True(t, ok)
+	require.Equal(t, "$OCTO_CONFIGS__POSTGRES__PASSWORD", entry.Origin.Source)
+
+	entry, ok = cfg.Provenance.Get("configs.postgres.port")
+	require.True(t, ok)
+	require.Len(t, entry.Shadowed, 2)
+	require.Equal(t, 6543, entry.Shadowed[1].Value)
+}
+
+func TestApplyEnvValues(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{}
+
+	WithEnvironment([]string{
+		"OCTO_A=0123",
+		"OCTO_B=1.10",
+		"OCTO_C=NaN",
+		"OCTO_D={\"a\":1}",
+		"OCTO_E={a,b}",
+		"OCTO_F=42",
+		"OCTO_G=true",
+		"OCTO_H=null",
+	})(cfg)
+
+	require.NoError(t, cfg.applyEnv())
+	require.Equal(t, map[string]any{
+		"a": "0123",
+		"b": "1.10",
+		"c": "NaN",
+		"d": `{"a":1}`,
+		"e": "{a,b}",
+		"f": 42,
+		"g": true,
+		"h": nil,
+	}, cfg.Data)
+
+	_, err := json.Marshal(cfg.Data)
+	require.NoError(t, err)
+}
@@ -0,0 +1,74 @@
+package octoconfig
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of files fetched at the same time if not configured.
+const DefaultConcurrency = 8
+
+// WithConcurrency sets how many includes, repositories and files get fetched at the same time.
+func WithConcurrency(n int) Option {
+	return func(c *Config) {
+		c.concurrency = n
+	}
+}
+
+// WithFetchTimeout sets the timeout of fetching a single include, repository or file, zero disables it.
+func WithFetchTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.fetchTimeout = timeout
+	}
+}
+
+// initFetch prepares the fetch slots for a run.
+func (c *Config) initFetch() {
+	n := c.concurrency
+	if n <= 0 {
+		n = DefaultConcurrency
+	}
+
+	c.fetchSlots = make(chan struct{}, n)
+}
+
+// fetch calls fn once a fetch slot is free, fn gets a context with the fetch timeout.
+func (c *Config) fetch(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c.fetchSlots != nil {
+		select {
+		case c.fetchSlots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		defer func() { <-c.fetchSlots }()
+	}
+
+	if c.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.fetchTimeout)
+		defer cancel()
+	}
+
+	return fn(ctx)
+}
+
+// parallel calls fn for each index in [0, n) concurrently and waits for all calls to return. The number of
+// concurrent fetches is limited by fetch, not by parallel.
+func parallel(n int, fn func(idx int)) {
+	wg := sync.WaitGroup{}
+
+	for idx := range n {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			fn(idx)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,80 @@
+package octoconfig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveSlowIncludes serves a.yaml .. f.yaml, each a list with its name, and records the concurrent requests.
+func serveSlowIncludes(t *testing.T, maxActive *atomic.Int32) string {
+	t.Helper()
+
+	active := atomic.Int32{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+
+		for {
+			seen := maxActive.Load()
+			if n <= seen || maxActive.CompareAndSwap(seen, n) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		name := strings.TrimSuffix(filepath.Base(req.URL.Path), ".yaml")
+		_, _ = fmt.Fprintf(w, "list:\n  - %s\n", name) //nolint:errcheck
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestReadConcurrent(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	maxActive := &atomic.Int32{}
+	serverURL := serveSlowIncludes(t, maxActive)
+
+	main := "include:\n"
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		main += fmt.Sprintf("  - url: %s/%s.yaml\n    gpg: none\n", serverURL, name)
+	}
+
+	dir := writeTestFiles(t, map[string]string{"main.yaml": main})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	cfg.concurrency = 3
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+
+	// The merge order doesn't depend on the order the includes finished in.
+	require.Equal(t, []any{"f", "e", "d", "c", "b", "a"}, cfg.Data["list"])
+	require.Equal(t, int32(3), maxActive.Load())
+}
+
+func TestFetchTimeout(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	serverURL := serveSlowIncludes(t, &atomic.Int32{})
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": "include:\n  - url: " + serverURL + "/a.yaml\n    gpg: none\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	cfg.fetchTimeout = time.Millisecond
+
+	require.ErrorIs(t, cfg.read(t.Context()), context.DeadlineExceeded)
+}
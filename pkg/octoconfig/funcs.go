@@ -0,0 +1,93 @@
+package octoconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrRequired happens when the `required` template function gets an empty value.
+var ErrRequired = errors.New("required value missing")
+
+// FuncMap returns the functions available in all templates, the sprig functions plus
+// `required`, `toYaml`, `fromYaml`, `toJson` and `fromJson`.
+func FuncMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+
+	funcs["required"] = required
+	funcs["toYaml"] = toYAML
+	funcs["fromYaml"] = fromYAML
+	funcs["toJson"] = toJSON
+	funcs["fromJson"] = fromJSON
+
+	return funcs
+}
+
+// NewTemplate creates a template with the FuncMap, name should point to the source of the template.
+func NewTemplate(name string) *template.Template {
+	return template.New(name).Funcs(FuncMap())
+}
+
+// NewTemplate creates a template with the FuncMap and the functions which generate persistent secrets
+// (`secret`, `uuid`, `rsaKey` and `rsaPublicKey`) of the project.
+func (c *Config) NewTemplate(name string) *template.Template {
+	return NewTemplate(name).Funcs(c.secretFuncs())
+}
+
+// required returns value or an error with msg if value is empty.
+func required(msg string, value any) (any, error) {
+	if value == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRequired, msg)
+	}
+
+	if s, ok := value.(string); ok && s == "" {
+		return nil, fmt.Errorf("%w: %s", ErrRequired, msg)
+	}
+
+	return value, nil
+}
+
+// toYAML encodes value as YAML without the trailing newline.
+func toYAML(value any) (string, error) {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// fromYAML decodes a YAML document.
+func fromYAML(text string) (any, error) {
+	var result any
+	if err := yaml.Unmarshal([]byte(text), &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// toJSON encodes value as JSON.
+func toJSON(value any) (string, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// fromJSON decodes a JSON document.
+func fromJSON(text string) (any, error) {
+	var result any
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
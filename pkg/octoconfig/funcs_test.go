@@ -0,0 +1,36 @@
+package octoconfig
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncMap(t *testing.T) {
+	tmpl, err := NewTemplate("test").Parse(
+		`{{ .missing | default "fallback" }} {{ "A" | lower | quote }} {{ "x" | b64enc }}` +
+			"\n{{ toYaml .list | indent 2 }}\n{{ (fromJson `{\"a\":1}`).a }}")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, tmpl.Execute(buf, map[string]any{"list": []any{"a", "b"}}))
+	require.Equal(t, "fallback \"a\" eA==\n  - a\n  - b\n1", buf.String())
+}
+
+func TestApplyServiceTemplatesRequired(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{"environment": map[string]any{"URI": `{{ required "configs.penpot.public_uri is required" .configs.penpot.public_uri }}`}},
+		},
+		"configs": map[string]any{"penpot": map[string]any{}},
+	}
+	cfg.provenance().record("", "", cfg.Data, "file:///charts/penpot.yaml", nil)
+
+	err := cfg.applyServiceTemplates()
+	require.ErrorIs(t, err, ErrRequired)
+	require.Contains(t, err.Error(), "template: services.penpot:")
+	require.Contains(t, err.Error(), "defined in file:///charts/penpot.yaml")
+	require.Contains(t, err.Error(), "configs.penpot.public_uri is required")
+}
@@ -0,0 +1,59 @@
+package octoconfig
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-orb/go-orb/codecs"
+	"github.com/octocompose/octoctl/pkg/octocache"
+)
+
+// expandInclude returns one include per file matching the glob of include in lexical order, a directory
+// include (`./conf.d/`) matches the files in it with a supported extension. Files in chain, like the
+// including file itself, are left out.
+func (c *Config) expandInclude(ctx context.Context, include *urlConfig, dir bool, chain []string) ([]*urlConfig, error) {
+	pattern, err := include.URL.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	if dir {
+		pattern.Path = strings.TrimSuffix(pattern.Path, "/") + "/*"
+	}
+
+	urls, err := octocache.ListURL(ctx, c.ProjectID, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*urlConfig{}
+
+	for _, url := range urls {
+		if dir {
+			if _, err := codecs.GetExt(filepath.Ext(url.Path)); err != nil {
+				continue
+			}
+		}
+
+		if slices.Contains(chain, url.String()) {
+			continue
+		}
+
+		expanded := &urlConfig{URL: url, When: include.When, Vars: include.Vars, Into: include.Into}
+
+		// A signature URL can't apply to several files, each file has its own.
+		if gpgDisabled(include.GPG) {
+			expanded.GPG = include.GPG
+		}
+
+		result = append(result, expanded)
+	}
+
+	if len(result) == 0 {
+		c.logger.Warn("Include matches no files", "url", include.URL.String())
+	}
+
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package octoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobIncludes(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml":   "include:\n  - url: ./services/*.yaml\n  - url: ./conf.d/\nlist:\n  - main\n",
+		"notes.txt":   "not included",
+		"README.yaml": "list:\n  - readme\n",
+	})
+
+	for name, content := range map[string]string{
+		"services/b.yaml":  "list:\n  - b\n",
+		"services/a.yaml":  "list:\n  - a\n",
+		"services/c.json":  `{"list": ["c"]}`,
+		"conf.d/10-x.yaml": "list:\n  - x\n",
+		"conf.d/20-y.toml": "list = [\"y\"]\n",
+		"conf.d/notes.txt": "not included",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+
+	require.NoError(t, cfg.read(t.Context()))
+
+	includes := []string{}
+	for _, include := range cfg.Paths[0].Includes {
+		includes = append(includes, include.URL.String())
+	}
+
+	require.Equal(t, []string{
+		"file://" + filepath.Join(dir, "services", "a.yaml"),
+		"file://" + filepath.Join(dir, "services", "b.yaml"),
+		"file://" + filepath.Join(dir, "conf.d", "10-x.yaml"),
+		"file://" + filepath.Join(dir, "conf.d", "20-y.toml"),
+	}, includes)
+
+	// Each file is its own include, later files have a lower priority like later includes.
+	require.NoError(t, cfg.merge(t.Context()))
+	require.Equal(t, []any{"y", "x", "b", "a", "main"}, cfg.Data["list"])
+}
+
+func TestGlobIncludeSelf(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"all.yaml":    "include:\n  - url: ./*.yaml\n",
+		"penpot.yaml": "list:\n  - penpot\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "all.yaml"))
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.Len(t, cfg.Paths[0].Includes, 1)
+}
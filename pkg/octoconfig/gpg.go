@@ -0,0 +1,251 @@
+package octoconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octocache"
+)
+
+const gpgNone = "none"
+
+// Signature verification states.
+const (
+	SignatureVerified = "verified"
+	SignatureSkipped  = "skipped"
+	SignatureDisabled = "disabled"
+	SignatureUnsigned = "unsigned"
+)
+
+// ErrNoTrustedKeys happens when a signature has to be verified but no trusted key is known for its source.
+var ErrNoTrustedKeys = errors.New("no trusted keys found")
+
+// SignatureResult represents the outcome of verifying a single include.
+type SignatureResult struct {
+	URL       string `json:"url"`
+	Signature string `json:"signature,omitempty"`
+	Status    string `json:"status"`
+	KeyID     string `json:"keyId,omitempty"`
+	Signer    string `json:"signer,omitempty"`
+}
+
+// gpgDisabled returns true if the signature URL is explicitly set to `none`.
+func gpgDisabled(gpg *config.URL) bool {
+	return gpg != nil && gpg.URL != nil && gpg.String() == gpgNone
+}
+
+// resolveGPG makes an explicit signature URL absolute or derives it from the include URL.
+// Local includes without an explicit signature URL are not signed.
+func resolveGPG(include *config.URL, gpg *config.URL, base *url.URL) (*config.URL, error) {
+	if gpgDisabled(gpg) {
+		return gpg, nil
+	}
+
+	if gpg != nil {
+		AbsURL(gpg.URL, base)
+		return gpg, nil
+	}
+
+	if include.Scheme == schemeFile {
+		return nil, nil //nolint:nilnil
+	}
+
+	result, err := include.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	result.URL.Path += gpgAsc
+
+	return result, nil
+}
+
+// KeyringPaths returns the directories searched for trusted keys of the given project and host.
+//
+// Keys for a project live in `<UserConfigDir>/octocompose/keys/projects/<projectID>`,
+// keys for a host in `<UserConfigDir>/octocompose/keys/hosts/<host>`.
+func KeyringPaths(projectID string, host string) ([]string, error) {
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{filepath.Join(userConfigDir, "octocompose", "keys", "projects", projectID)}
+
+	if host != "" {
+		result = append(result, filepath.Join(userConfigDir, "octocompose", "keys", "hosts", host))
+	}
+
+	return result, nil
+}
+
+// readKeyringFile reads an armored or binary OpenPGP keyring.
+func readKeyringFile(path string) (openpgp.EntityList, error) {
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("while reading keyring '%s': %w", path, err)
+	}
+
+	if keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b)); err == nil {
+		return keyring, nil
+	}
+
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("while parsing keyring '%s': %w", path, err)
+	}
+
+	return keyring, nil
+}
+
+// keyring returns all trusted keys for the given host.
+func (c *Config) keyring(host string) (openpgp.EntityList, error) {
+	dirs, err := KeyringPaths(c.ProjectID, host)
+	if err != nil {
+		return nil, err
+	}
+
+	files := append([]string{}, c.keyrings...)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, fmt.Errorf("while reading keyring directory '%s': %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	result := openpgp.EntityList{}
+
+	for _, file := range files {
+		keyring, err := readKeyringFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, keyring...)
+	}
+
+	return result, nil
+}
+
+// verify verifies the cached copy of url against its detached signature gpg.
+func (c *Config) verify(ctx context.Context, url *config.URL, cached *config.URL, gpg *config.URL, cacheType string) error {
+	result := SignatureResult{URL: url.String(), Signature: gpg.String()}
+
+	defer func() {
+		c.mu.Lock()
+		c.Signatures = append(c.Signatures, result)
+		c.mu.Unlock()
+	}()
+
+	switch {
+	case c.insecureSkipVerify:
+		result.Status = SignatureSkipped
+		c.logger.Warn("Skipping signature verification", "url", url.String())
+
+		return nil
+	case gpgDisabled(gpg):
+		result.Status = SignatureDisabled
+		c.logger.Warn("Signature verification disabled", "url", url.String())
+
+		return nil
+	case gpg == nil:
+		result.Status = SignatureUnsigned
+		c.logger.Trace("Local file is not signed", "url", url.String())
+
+		return nil
+	}
+
+	if err := c.checkSignature(ctx, url, cached, gpg, cacheType, &result); err != nil {
+		// Don't keep unverified content in the cache.
+		if cached.Scheme == schemeFile && url.Scheme != schemeFile {
+			if err := os.Remove(cached.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				c.logger.Error("failed to remove unverified file", "path", cached.Path, "error", err)
+			}
+		}
+
+		return err
+	}
+
+	result.Status = SignatureVerified
+	c.logger.Debug("Verified signature", "url", url.String(), "keyID", result.KeyID, "signer", result.Signer)
+
+	return nil
+}
+
+func (c *Config) checkSignature(
+	ctx context.Context,
+	url *config.URL,
+	cached *config.URL,
+	gpg *config.URL,
+	cacheType string,
+	result *SignatureResult,
+) error {
+	keyring, err := c.keyring(url.Host)
+	if err != nil {
+		return err
+	}
+
+	if len(keyring) == 0 {
+		return fmt.Errorf("while verifying '%s': %w for project '%s' and host '%s'", url.String(), ErrNoTrustedKeys, c.ProjectID, url.Host)
+	}
+
+	cachedSig, err := octocache.CachedURL(ctx, c.ProjectID, gpg, nil, cacheType, true)
+	if err != nil {
+		return fmt.Errorf("while fetching signature for '%s': %w", url.String(), err)
+	}
+
+	content, err := os.ReadFile(cached.Path)
+	if err != nil {
+		return fmt.Errorf("while reading '%s': %w", cached.String(), err)
+	}
+
+	signature, err := os.ReadFile(cachedSig.Path)
+	if err != nil {
+		return fmt.Errorf("while reading signature '%s': %w", cachedSig.String(), err)
+	}
+
+	var signer *openpgp.Entity
+	if strings.HasPrefix(strings.TrimSpace(string(signature)), "-----BEGIN") {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	}
+
+	if err != nil {
+		if gpg.Scheme != schemeFile {
+			if err := os.Remove(cachedSig.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				c.logger.Error("failed to remove signature", "path", cachedSig.Path, "error", err)
+			}
+		}
+
+		return fmt.Errorf("while verifying signature '%s' of '%s': %w", gpg.String(), url.String(), err)
+	}
+
+	result.KeyID = signer.PrimaryKey.KeyIdString()
+	if identity := signer.PrimaryIdentity(); identity != nil {
+		result.Signer = identity.Name
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package octoconfig
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSignedFile(t *testing.T, dir string, content string) (*config.URL, *config.URL, string) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("octoctl test", "", "test@octocompose.dev", nil)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	sig := &bytes.Buffer{}
+	require.NoError(t, openpgp.ArmoredDetachSign(sig, entity, bytes.NewReader([]byte(content)), nil))
+	require.NoError(t, os.WriteFile(path+gpgAsc, sig.Bytes(), 0o600))
+
+	keyring := &bytes.Buffer{}
+	w, err := armor.Encode(keyring, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	keyringPath := filepath.Join(dir, "trusted.asc")
+	require.NoError(t, os.WriteFile(keyringPath, keyring.Bytes(), 0o600))
+
+	url, err := config.NewURL("file://" + path)
+	require.NoError(t, err)
+
+	gpg, err := config.NewURL("file://" + path + gpgAsc)
+	require.NoError(t, err)
+
+	return url, gpg, keyringPath
+}
+
+func TestResolveGPG(t *testing.T) {
+	base, err := config.NewURL("https://example.com/charts/app.yaml")
+	require.NoError(t, err)
+
+	remote, err := config.NewURL("https://example.com/charts/include.yaml")
+	require.NoError(t, err)
+
+	gpg, err := resolveGPG(remote, nil, base.URL)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/charts/include.yaml.asc", gpg.String())
+
+	local, err := config.NewURL("file:///charts/include.yaml")
+	require.NoError(t, err)
+
+	gpg, err = resolveGPG(local, nil, base.URL)
+	require.NoError(t, err)
+	require.Nil(t, gpg)
+
+	none, err := config.NewURL(gpgNone)
+	require.NoError(t, err)
+
+	gpg, err = resolveGPG(remote, none, base.URL)
+	require.NoError(t, err)
+	require.True(t, gpgDisabled(gpg))
+}
+
+func TestVerify(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	url, gpg, keyringPath := writeSignedFile(t, t.TempDir(), "name: test\n")
+
+	cfg := setupTestConfig()
+	cfg.keyrings = []string{keyringPath}
+
+	require.NoError(t, cfg.verify(t.Context(), url, url, gpg, "configs"))
+	require.Len(t, cfg.Signatures, 1)
+	require.Equal(t, SignatureVerified, cfg.Signatures[0].Status)
+	require.Equal(t, "octoctl test <test@octocompose.dev>", cfg.Signatures[0].Signer)
+
+	// Tamper with the content.
+	require.NoError(t, os.WriteFile(url.Path, []byte("name: evil\n"), 0o600))
+	require.Error(t, cfg.verify(t.Context(), url, url, gpg, "configs"))
+}
+
+func TestVerifyNoTrustedKeys(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	url, gpg, _ := writeSignedFile(t, t.TempDir(), "name: test\n")
+
+	cfg := setupTestConfig()
+	require.ErrorIs(t, cfg.verify(t.Context(), url, url, gpg, "configs"), ErrNoTrustedKeys)
+
+	cfg.insecureSkipVerify = true
+	require.NoError(t, cfg.verify(t.Context(), url, url, gpg, "configs"))
+	require.Equal(t, SignatureSkipped, cfg.Signatures[len(cfg.Signatures)-1].Status)
+}
@@ -0,0 +1,135 @@
+package octoconfig
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-orb/go-orb/config"
+)
+
+// Include deduplication modes.
+const (
+	// IncludeDedupeFirst merges an include only at its first occurrence in include order, this is the default.
+	IncludeDedupeFirst = "first"
+	// IncludeDedupeLast merges an include only at its last occurrence in include order.
+	IncludeDedupeLast = "last"
+	// IncludeDedupeNone merges an include at every occurrence.
+	IncludeDedupeNone = "none"
+)
+
+// ErrIncludeCycle happens when includes include each other.
+var ErrIncludeCycle = errors.New("include cycle")
+
+// ErrIncludeDedupe happens on unknown include deduplication modes.
+var ErrIncludeDedupe = errors.New("invalid include dedupe mode")
+
+// checkDedupe returns an error if mode isn't a deduplication mode, empty means IncludeDedupeFirst.
+func checkDedupe(mode string) error {
+	modes := []string{IncludeDedupeFirst, IncludeDedupeLast, IncludeDedupeNone}
+	if mode == "" || slices.Contains(modes, mode) {
+		return nil
+	}
+
+	return fmt.Errorf("%w '%s': expected one of %s", ErrIncludeDedupe, mode, strings.Join(modes, ", "))
+}
+
+// checkCycle returns an error with the include chain if url is already part of chain.
+func checkCycle(chain []string, url *config.URL) error {
+	if !slices.Contains(chain, url.String()) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(slices.Clone(chain), url.String()), " -> "))
+}
+
+// findCycle returns an ErrIncludeCycle error with the include chain for the first cycle reachable from node.
+// Concurrent reads only check the chain of their own branch, a cycle through a sibling branch shows up here.
+func findCycle[T fmt.Stringer](node T, children func(T) []T, chain []string, done map[string]struct{}) error {
+	key := node.String()
+
+	if slices.Contains(chain, key) {
+		return fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(slices.Clone(chain), key), " -> "))
+	}
+
+	if _, ok := done[key]; ok {
+		return nil
+	}
+
+	chain = append(chain, key)
+
+	for _, child := range children(node) {
+		if err := findCycle(child, children, chain, done); err != nil {
+			return err
+		}
+	}
+
+	done[key] = struct{}{}
+
+	return nil
+}
+
+// checkCycles returns an error if the includes or repository includes read form a cycle.
+func (c *Config) checkCycles() error {
+	includes := func(u *urlConfig) []*urlConfig {
+		return slices.DeleteFunc(slices.Clone(u.Includes), func(include *urlConfig) bool { return include.pending })
+	}
+
+	children := func(r *Repo) []*Repo {
+		return slices.DeleteFunc(slices.Clone(r.Children), func(child *Repo) bool { return child.pending })
+	}
+
+	configsDone := map[string]struct{}{}
+	reposDone := map[string]struct{}{}
+
+	for _, path := range c.Paths {
+		if err := findCycle(path, includes, nil, configsDone); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range c.Paths {
+		for cfg := range path.Flatten() {
+			if cfg.Repo == nil {
+				continue
+			}
+
+			if err := findCycle(cfg.Repo, children, nil, reposDone); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// dedupe removes includes which occur more than once according to mode.
+func dedupe[T fmt.Stringer](items []T, mode string) []T {
+	if mode == IncludeDedupeNone {
+		return items
+	}
+
+	if mode == IncludeDedupeLast {
+		items = slices.Clone(items)
+		slices.Reverse(items)
+	}
+
+	seen := map[string]struct{}{}
+	result := make([]T, 0, len(items))
+
+	for _, item := range items {
+		if _, ok := seen[item.String()]; ok {
+			continue
+		}
+
+		seen[item.String()] = struct{}{}
+		result = append(result, item)
+	}
+
+	if mode == IncludeDedupeLast {
+		slices.Reverse(result)
+	}
+
+	return result
+}
@@ -0,0 +1,145 @@
+package octoconfig
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	return dir
+}
+
+func testConfigForFile(t *testing.T, path string) *Config {
+	t.Helper()
+
+	url, err := config.NewURL("file://" + path)
+	require.NoError(t, err)
+
+	cfg := setupTestConfig()
+	cfg.Paths = []*urlConfig{{URL: url}}
+
+	return cfg
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a.yaml": "include:\n  - url: ./b.yaml\n",
+		"b.yaml": "include:\n  - url: ./a.yaml\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "a.yaml"))
+
+	err := cfg.read(t.Context())
+	require.ErrorIs(t, err, ErrIncludeCycle)
+	require.Contains(t, err.Error(), "a.yaml -> file://"+dir+"/b.yaml -> file://"+dir+"/a.yaml")
+}
+
+func TestIncludeCycleSiblings(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml":   "include:\n  - url: ./b.yaml\n  - url: ./c.yaml\nrepos:\n  include:\n    - url: ./repo-b.yaml\n    - url: ./repo-c.yaml\n",
+		"b.yaml":      "include:\n  - url: ./c.yaml\n",
+		"c.yaml":      "include:\n  - url: ./b.yaml\n",
+		"repo-b.yaml": "include:\n  - url: ./repo-c.yaml\n",
+		"repo-c.yaml": "include:\n  - url: ./repo-b.yaml\n",
+	})
+
+	// Concurrent reads of the siblings only see their own chain, the order they finish in varies.
+	for range 10 {
+		cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+
+		require.ErrorIs(t, cfg.read(t.Context()), ErrIncludeCycle)
+
+		// Flattening terminates even with the cycle.
+		require.Len(t, cfg.collectConfigs(), 3)
+		require.Len(t, cfg.collectRepos(), 5)
+	}
+}
+
+func TestIncludeDiamond(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml":   "include:\n  - url: ./left.yaml\n  - url: ./right.yaml\n",
+		"left.yaml":   "include:\n  - url: ./shared.yaml\n",
+		"right.yaml":  "include:\n  - url: ./shared.yaml\n",
+		"shared.yaml": "list:\n  - shared\n",
+	})
+
+	for mode, expected := range map[string][]any{
+		IncludeDedupeFirst: {"shared"},
+		IncludeDedupeLast:  {"shared"},
+		IncludeDedupeNone:  {"shared", "shared"},
+	} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+			cfg.includeDedupe = mode
+
+			require.NoError(t, cfg.read(t.Context()))
+			require.NoError(t, cfg.merge(t.Context()))
+			require.Equal(t, expected, cfg.Data["list"])
+		})
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	urls := []*config.URL{}
+	for _, u := range []string{"file:///a", "file:///b", "file:///a", "file:///c"} {
+		url, err := config.NewURL(u)
+		require.NoError(t, err)
+
+		urls = append(urls, url)
+	}
+
+	require.Equal(t, []*config.URL{urls[0], urls[1], urls[3]}, dedupe(urls, IncludeDedupeFirst))
+	require.Equal(t, []*config.URL{urls[1], urls[2], urls[3]}, dedupe(urls, IncludeDedupeLast))
+	require.Equal(t, urls, dedupe(urls, IncludeDedupeNone))
+}
+
+func TestDedupeInvalidMode(t *testing.T) {
+	_, err := New(setupTestConfig().logger, false, nil, nil, WithIncludeDedupe("latest"))
+	require.ErrorIs(t, err, ErrIncludeDedupe)
+	require.ErrorContains(t, err, "expected one of first, last, none")
+
+	for _, mode := range []string{"", IncludeDedupeFirst, IncludeDedupeLast, IncludeDedupeNone} {
+		_, err := New(setupTestConfig().logger, false, nil, nil, WithIncludeDedupe(mode))
+		require.NoError(t, err)
+	}
+}
+
+func TestAbsURLGit(t *testing.T) {
+	base, err := url.Parse("git+ssh://git@example.com/org/repo.git//configs/app.yaml?ref=v1.2")
+	require.NoError(t, err)
+
+	include, err := url.Parse("../shared/base.yaml")
+	require.NoError(t, err)
+
+	AbsURL(include, base)
+	require.Equal(t, "git+ssh://git@example.com/org/repo.git//shared/base.yaml?ref=v1.2", include.String())
+
+	include, err = url.Parse("https://example.com/other.yaml")
+	require.NoError(t, err)
+
+	AbsURL(include, base)
+	require.Equal(t, "https://example.com/other.yaml", include.String())
+}
+
+func TestAbsURLOCI(t *testing.T) {
+	base, err := url.Parse("oci://registry.example.com/org/penpot:1.2.0//config/all.yaml")
+	require.NoError(t, err)
+
+	include, err := url.Parse("../repos/penpot.yaml")
+	require.NoError(t, err)
+
+	AbsURL(include, base)
+	require.Equal(t, "oci://registry.example.com/org/penpot:1.2.0//repos/penpot.yaml", include.String())
+}
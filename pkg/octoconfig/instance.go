@@ -0,0 +1,202 @@
+package octoconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template/parse"
+)
+
+// ErrInto happens when the `into` path of an include is invalid.
+var ErrInto = errors.New("invalid into path")
+
+// varsKey is the template variable which holds the vars of all include instances by instance.
+const varsKey = "_vars"
+
+// intoPattern matches dot separated `into` paths like `configs.db2`.
+//
+//nolint:gochecknoglobals
+var intoPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// instantiate sets the mount path and the vars of include, both get inherited from parent. Includes with
+// vars or a mount path are instances, the same URL gets read once per instance.
+func instantiate(parent *urlConfig, include *urlConfig) error {
+	if include.Into != "" && !intoPattern.MatchString(include.Into) {
+		return fmt.Errorf("%w '%s' of '%s': expected a dot separated path like 'configs.db2'", ErrInto, include.Into, include.URL.String())
+	}
+
+	include.mount = parent.mount
+	if include.Into != "" {
+		include.mount = strings.TrimPrefix(parent.mount+"."+include.Into, ".")
+	}
+
+	include.vars = maps.Clone(parent.vars)
+	if len(include.Vars) > 0 {
+		if include.vars == nil {
+			include.vars = map[string]any{}
+		}
+
+		maps.Copy(include.vars, include.Vars)
+	}
+
+	include.instance = ""
+
+	if include.mount == "" && len(include.vars) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(map[string]any{"into": include.mount, "vars": include.vars})
+	if err != nil {
+		return fmt.Errorf("while encoding the vars of '%s': %w", include.URL.String(), err)
+	}
+
+	sum := sha256.Sum256(b)
+	include.instance = "v" + hex.EncodeToString(sum[:6])
+
+	return nil
+}
+
+// bindVars makes `.vars` in the templates of data refer to the vars of instance.
+func bindVars(data any, instance string) any {
+	switch typed := data.(type) {
+	case map[string]any:
+		for key, value := range typed {
+			typed[key] = bindVars(value, instance)
+		}
+	case []any:
+		for idx, value := range typed {
+			typed[idx] = bindVars(value, instance)
+		}
+	case string:
+		return bindTemplateVars(typed, instance)
+	}
+
+	return data
+}
+
+// bindTemplateVars rewrites the `.vars` and `$.vars` references of the template text to the vars of instance.
+// Text which isn't a template, doesn't parse or defines templates is returned as is.
+func bindTemplateVars(text string, instance string) string {
+	if !strings.Contains(text, "{{") || !strings.Contains(text, "vars") {
+		return text
+	}
+
+	tree := parse.New("vars")
+	tree.Mode = parse.SkipFuncCheck
+
+	treeSet := map[string]*parse.Tree{}
+	if _, err := tree.Parse(text, "", "", treeSet); err != nil || len(treeSet) > 1 {
+		return text
+	}
+
+	rewriter := &varsRewriter{instance: instance}
+	rewriter.walk(tree.Root, true)
+
+	if !rewriter.changed {
+		return text
+	}
+
+	return tree.Root.String()
+}
+
+// varsRewriter rewrites the references to `vars` of a template tree.
+type varsRewriter struct {
+	instance string
+	changed  bool
+}
+
+// walk walks node, root is true while dot is the template data.
+func (v *varsRewriter) walk(node parse.Node, root bool) {
+	switch typed := node.(type) {
+	case *parse.ListNode:
+		if typed == nil {
+			return
+		}
+
+		for _, child := range typed.Nodes {
+			v.walk(child, root)
+		}
+	case *parse.ActionNode:
+		v.walk(typed.Pipe, root)
+	case *parse.TemplateNode:
+		v.walk(typed.Pipe, root)
+	case *parse.PipeNode:
+		if typed == nil {
+			return
+		}
+
+		for _, cmd := range typed.Cmds {
+			v.walk(cmd, root)
+		}
+	case *parse.CommandNode:
+		for _, arg := range typed.Args {
+			v.walk(arg, root)
+		}
+	case *parse.ChainNode:
+		v.walk(typed.Node, root)
+	case *parse.IfNode:
+		v.walk(typed.Pipe, root)
+		v.walk(typed.List, root)
+		v.walk(typed.ElseList, root)
+	case *parse.WithNode:
+		v.walk(typed.Pipe, root)
+		v.walk(typed.List, false)
+		v.walk(typed.ElseList, root)
+	case *parse.RangeNode:
+		v.walk(typed.Pipe, root)
+		v.walk(typed.List, false)
+		v.walk(typed.ElseList, root)
+	case *parse.FieldNode:
+		if root && typed.Ident[0] == "vars" {
+			typed.Ident = slices.Concat([]string{varsKey, v.instance}, typed.Ident[1:])
+			v.changed = true
+		}
+	case *parse.VariableNode:
+		if len(typed.Ident) > 1 && typed.Ident[0] == "$" && typed.Ident[1] == "vars" {
+			typed.Ident = slices.Concat([]string{"$", varsKey, v.instance}, typed.Ident[2:])
+			v.changed = true
+		}
+	}
+}
+
+// mountData returns data nested below the mount path of u.
+func (u *urlConfig) mountData(data map[string]any) map[string]any {
+	if u.mount == "" {
+		return data
+	}
+
+	keys := strings.Split(u.mount, ".")
+	for _, key := range slices.Backward(keys) {
+		data = map[string]any{key: data}
+	}
+
+	return data
+}
+
+// mountPaths returns paths with the mount path of u in front of each path.
+func mountPaths[V any](u *urlConfig, paths map[string]V) map[string]V {
+	if u.mount == "" {
+		return paths
+	}
+
+	result := make(map[string]V, len(paths))
+
+	for path, value := range paths {
+		switch {
+		case path == "":
+			result[u.mount] = value
+		case strings.HasPrefix(path, "["):
+			result[u.mount+path] = value
+		default:
+			result[u.mount+"."+path] = value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,89 @@
+package octoconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludeInstances(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `include:
+  - url: ./postgres.yaml
+    into: configs.db1
+    vars:
+      name: db1
+  - url: ./postgres.yaml
+    into: configs.db2
+    vars:
+      name: db2
+      port: 5433
+  - url: ./postgres.yaml
+configs:
+  app:
+    database: "{{ .configs.db2.dsn }}"
+`,
+		"postgres.yaml": `include:
+  - url: ./defaults.yaml
+dsn: "postgres://{{ .vars.name }}:{{ .vars.port | default 5432 }}/{{ .configs.app.schema }}"
+`,
+		"defaults.yaml": "user: postgres\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	cfg.HardcodedData = map[string]any{"configs": map[string]any{"app": map[string]any{"schema": "app"}}}
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.Len(t, cfg.collectConfigs(), 7)
+	require.NoError(t, cfg.merge(t.Context()))
+	require.NoError(t, cfg.resolveValues())
+
+	configs, ok := cfg.Data["configs"].(map[string]any)
+	require.True(t, ok)
+
+	require.Equal(t, map[string]any{"dsn": "postgres://db1:5432/app", "user": "postgres"}, configs["db1"])
+	require.Equal(t, map[string]any{"dsn": "postgres://db2:5433/app", "user": "postgres"}, configs["db2"])
+	require.Equal(t, "postgres://db2:5433/app", configs["app"].(map[string]any)["database"]) //nolint:forcetypeassert
+
+	// Without into the include gets merged at the root.
+	require.Equal(t, "postgres", cfg.Data["user"])
+
+	entry, ok := cfg.Provenance.Get("configs.db2.dsn")
+	require.True(t, ok)
+	require.Equal(t, "file://"+filepath.Join(dir, "postgres.yaml"), entry.Origin.Source)
+	require.Equal(t, 3, entry.Origin.Line)
+}
+
+func TestIncludeInvalidInto(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml":  "include:\n  - url: ./other.yaml\n    into: configs..db\n",
+		"other.yaml": "name: other\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+
+	require.ErrorIs(t, cfg.read(t.Context()), ErrInto)
+}
+
+func TestBindVars(t *testing.T) {
+	data := map[string]any{
+		"a": "{{ .vars.name }}",
+		"b": []any{"{{ $.vars.name }}", "{{ .configs.vars.name }}", "{{ $x := 1 }}{{ $x.vars }}", ".vars"},
+		"c": `{{ printf ".vars %s" .vars.name }}`,
+		"d": "{{ with .configs }}{{ .vars.name }}{{ $.vars.name }}{{ end }}",
+		"e": "{{ .configs.penpot.name }}",
+	}
+
+	bindVars(data, "v1")
+
+	require.Equal(t, map[string]any{
+		"a": "{{._vars.v1.name}}",
+		"b": []any{"{{$._vars.v1.name}}", "{{ .configs.vars.name }}", "{{ $x := 1 }}{{ $x.vars }}", ".vars"},
+		// String literals are left alone.
+		"c": `{{printf ".vars %s" ._vars.v1.name}}`,
+		// Inside with dot is another value, only $.vars refers to the vars.
+		"d": "{{with .configs}}{{.vars.name}}{{$._vars.v1.name}}{{end}}",
+		"e": "{{ .configs.penpot.name }}",
+	}, data)
+}
@@ -0,0 +1,355 @@
+package octoconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Merge strategies, set with a `$merge` key or a YAML tag like `!replace`.
+const (
+	// MergeMerge merges maps and merges lists of maps with a `name` key by name, other lists get appended.
+	MergeMerge = "merge"
+	// MergeReplace replaces the value of lower priority files.
+	MergeReplace = "replace"
+	// MergeAppend appends a list to the list of lower priority files.
+	MergeAppend = "append"
+	// MergePrepend prepends a list to the list of lower priority files.
+	MergePrepend = "prepend"
+	// MergeUnique appends the items of a list which aren't in the list of lower priority files.
+	MergeUnique = "unique"
+)
+
+// mergeKey is the key of the merge directives in maps.
+const mergeKey = "$merge"
+
+// mergeNameKey is the key lists of maps get merged by.
+const mergeNameKey = "name"
+
+// ErrMergeStrategy happens on unknown merge strategies or strategies which don't fit the value.
+var ErrMergeStrategy = errors.New("invalid merge strategy")
+
+// readStrategies removes the `$merge` directives from data and returns the merge strategy of each path,
+// tags contains the strategies given as YAML tags. A `$merge` string applies to its map, a `$merge` map
+// sets the strategies of the keys of its map.
+func readStrategies(source string, data map[string]any, tags map[string]string) (map[string]string, error) {
+	result := map[string]string{}
+
+	for path, strategy := range tags {
+		result[path] = strategy
+	}
+
+	if err := walkStrategies(source, "", data, result); err != nil {
+		return nil, err
+	}
+
+	for path, strategy := range result {
+		if !slices.Contains([]string{MergeMerge, MergeReplace, MergeAppend, MergePrepend, MergeUnique}, strategy) {
+			return nil, fmt.Errorf("%w '%s' at %s in '%s'", ErrMergeStrategy, strategy, path, source)
+		}
+	}
+
+	return result, nil
+}
+
+func walkStrategies(source string, path string, data any, result map[string]string) error {
+	switch typed := data.(type) {
+	case map[string]any:
+		switch directive := typed[mergeKey].(type) {
+		case nil:
+		case string:
+			result[path] = directive
+		case map[string]any:
+			for key, strategy := range directive {
+				name, ok := strategy.(string)
+				if !ok {
+					return fmt.Errorf("%w at %s in '%s': expected a string", ErrMergeStrategy, joinPath(path, key), source)
+				}
+
+				result[joinPath(path, key)] = name
+			}
+		default:
+			return fmt.Errorf("%w at %s in '%s': expected a string or a map", ErrMergeStrategy, path, source)
+		}
+
+		delete(typed, mergeKey)
+
+		for key, value := range typed {
+			if err := walkStrategies(source, joinPath(path, key), value, result); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for idx, item := range typed {
+			if err := walkStrategies(source, fmt.Sprintf("%s[%d]", path, idx), item, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// yamlTags returns the local tags (`!replace`) of the values in a YAML file, keyed by their path.
+func yamlTags(path string) map[string]string {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return nil
+	}
+
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	result := map[string]string{}
+	walkYAMLTags("", doc.Content[0], result)
+
+	return result
+}
+
+func walkYAMLTags(path string, node *yaml.Node, result map[string]string) {
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		result[path] = strings.TrimPrefix(node.Tag, "!")
+	}
+
+	switch node.Kind { //nolint:exhaustive
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			walkYAMLTags(joinPath(path, node.Content[idx].Value), node.Content[idx+1], result)
+		}
+	case yaml.SequenceNode:
+		for idx, item := range node.Content {
+			walkYAMLTags(fmt.Sprintf("%s[%d]", path, idx), item, result)
+		}
+	}
+}
+
+// merger merges the data of source into the data of lower priority sources, it records the provenance if set.
+type merger struct {
+	provenance *Provenance
+	source     string
+	lines      map[string]int
+	strategies map[string]string
+}
+
+// merge merges src into dst and returns dst, path is the path in the merged data and filePath the one in source.
+// src gets copied, it's never shared with dst.
+func (m *merger) merge(dst map[string]any, src map[string]any, path string, filePath string) (map[string]any, error) {
+	if dst == nil {
+		dst = map[string]any{}
+	}
+
+	for key, value := range src {
+		existing, exists := dst[key]
+
+		merged, err := m.value(existing, exists, value, joinPath(path, key), joinPath(filePath, key))
+		if err != nil {
+			return nil, err
+		}
+
+		dst[key] = merged
+	}
+
+	return dst, nil
+}
+
+// value merges a single value, exists is false if dst has no value at path.
+func (m *merger) value(dst any, exists bool, src any, path string, filePath string) (any, error) {
+	strategy := m.strategies[filePath]
+
+	switch typed := src.(type) {
+	case map[string]any:
+		dstMap, ok := dst.(map[string]any)
+
+		switch strategy {
+		case "", MergeMerge:
+			if !ok && exists {
+				return m.replace(path, func() (any, error) { return m.merge(nil, typed, path, filePath) })
+			}
+
+			return m.merge(dstMap, typed, path, filePath)
+		case MergeReplace:
+			return m.replace(path, func() (any, error) { return m.merge(nil, typed, path, filePath) })
+		}
+
+		return nil, fmt.Errorf("%w '%s' at %s in '%s': only merge and replace apply to maps", ErrMergeStrategy, strategy, filePath, m.source)
+	case []any:
+		dstList, ok := dst.([]any)
+		if !ok && exists {
+			return m.replace(path, func() (any, error) { return m.list(nil, typed, path, filePath, MergeAppend) })
+		}
+
+		if strategy == MergeReplace {
+			return m.replace(path, func() (any, error) { return m.list(nil, typed, path, filePath, MergeAppend) })
+		}
+
+		return m.list(dstList, typed, path, filePath, strategy)
+	}
+
+	// Empty values don't override existing ones.
+	if exists && isEmptyValue(src) {
+		return dst, nil
+	}
+
+	switch dst.(type) {
+	case map[string]any, []any:
+		return m.replace(path, func() (any, error) {
+			m.record(path, filePath, src)
+			return src, nil
+		})
+	}
+
+	m.record(path, filePath, src)
+
+	return src, nil
+}
+
+// list merges the list src into dst with strategy.
+func (m *merger) list(dst []any, src []any, path string, filePath string, strategy string) ([]any, error) {
+	result := slices.Clone(dst)
+
+	if strategy == MergePrepend {
+		if m.provenance != nil {
+			m.provenance.shift(path, len(src))
+		}
+
+		prepended := make([]any, 0, len(src)+len(dst))
+
+		for idx, item := range src {
+			merged, err := m.value(nil, false, item, fmt.Sprintf("%s[%d]", path, idx), fmt.Sprintf("%s[%d]", filePath, idx))
+			if err != nil {
+				return nil, err
+			}
+
+			prepended = append(prepended, merged)
+		}
+
+		return append(prepended, result...), nil
+	}
+
+	keyed := (strategy == "" || strategy == MergeMerge) && len(dst) > 0 && keyedList(dst) && keyedList(src)
+
+	for idx, item := range src {
+		itemFilePath := fmt.Sprintf("%s[%d]", filePath, idx)
+
+		if keyed {
+			name := item.(map[string]any)[mergeNameKey] //nolint:errcheck
+
+			if target := slices.IndexFunc(result, func(existing any) bool {
+				return existing.(map[string]any)[mergeNameKey] == name //nolint:errcheck
+			}); target >= 0 {
+				merged, err := m.value(result[target], true, item, fmt.Sprintf("%s[%d]", path, target), itemFilePath)
+				if err != nil {
+					return nil, err
+				}
+
+				result[target] = merged
+
+				continue
+			}
+		}
+
+		if strategy == MergeUnique && slices.ContainsFunc(result, func(existing any) bool { return reflect.DeepEqual(existing, item) }) {
+			continue
+		}
+
+		merged, err := m.value(nil, false, item, fmt.Sprintf("%s[%d]", path, len(result)), itemFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, merged)
+	}
+
+	return result, nil
+}
+
+// replace replaces the value at path with the result of fn, the values it replaces get shadowed.
+func (m *merger) replace(path string, fn func() (any, error)) (any, error) {
+	if m.provenance == nil {
+		return fn()
+	}
+
+	replaced := m.provenance.remove(path)
+
+	result, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	m.provenance.shadow(replaced)
+
+	return result, nil
+}
+
+// record records the leaf value at path.
+func (m *merger) record(path string, filePath string, value any) {
+	if m.provenance != nil {
+		m.provenance.record(path, filePath, value, m.source, m.lines)
+	}
+}
+
+// keyedList returns true if all items of list are maps with a `name`.
+func keyedList(list []any) bool {
+	for _, item := range list {
+		data, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+
+		if name, ok := data[mergeNameKey].(string); !ok || name == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shift moves the entries of the list at path n items back.
+func (p *Provenance) shift(path string, n int) {
+	moved := map[string]*ProvenanceEntry{}
+
+	for entryPath, entry := range p.entries {
+		rest, ok := strings.CutPrefix(entryPath, path+"[")
+		if !ok {
+			continue
+		}
+
+		idxStr, tail, ok := strings.Cut(rest, "]")
+
+		idx, err := strconv.Atoi(idxStr)
+		if !ok || err != nil {
+			continue
+		}
+
+		delete(p.entries, entryPath)
+
+		entry.Path = fmt.Sprintf("%s[%d]%s", path, idx+n, tail)
+		moved[entry.Path] = entry
+	}
+
+	for entryPath, entry := range moved {
+		p.entries[entryPath] = entry
+	}
+}
+
+// shadow puts the origins of the replaced entries below the entries now at their paths.
+func (p *Provenance) shadow(replaced []*ProvenanceEntry) {
+	for _, old := range replaced {
+		if entry, ok := p.entries[old.Path]; ok {
+			entry.Shadowed = append(append(entry.Shadowed, old.Shadowed...), old.Origin)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package octoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStrategies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("services:\n  penpot:\n    command: !replace\n      - run\n"), 0o600))
+
+	data := map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{
+				"$merge":  map[string]any{"ports": "unique"},
+				"command": []any{"run"},
+				"ports":   []any{"80"},
+			},
+			"exporter": map[string]any{"$merge": "replace"},
+		},
+	}
+
+	strategies, err := readStrategies("config.yaml", data, yamlTags(path))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"services.penpot.command": MergeReplace,
+		"services.penpot.ports":   MergeUnique,
+		"services.exporter":       MergeReplace,
+	}, strategies)
+	require.NotContains(t, data["services"].(map[string]any)["penpot"], "$merge")
+
+	_, err = readStrategies("config.yaml", map[string]any{"a": map[string]any{"$merge": "drop"}}, nil)
+	require.ErrorIs(t, err, ErrMergeStrategy)
+}
+
+func TestMergeStrategies(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = nil
+
+	url1, err := config.NewURL("file:///path/to/config.yaml")
+	require.NoError(t, err)
+	url2, err := config.NewURL("file:///path/to/chart.yaml")
+	require.NoError(t, err)
+
+	chart := &urlConfig{
+		URL: url2,
+		Data: map[string]any{
+			"command":  []any{"run", "--debug"},
+			"ports":    []any{"80", "443"},
+			"volumes":  []any{"data"},
+			"env":      map[string]any{"A": "1", "B": "2"},
+			"sidecars": []any{map[string]any{"name": "proxy", "image": "nginx"}, map[string]any{"name": "cron", "image": "cron"}},
+		},
+	}
+
+	user := &urlConfig{
+		URL: url1,
+		Data: map[string]any{
+			"command":  []any{"run"},
+			"ports":    []any{"443", "8080"},
+			"volumes":  []any{"config"},
+			"env":      map[string]any{"A": "3"},
+			"sidecars": []any{map[string]any{"name": "proxy", "image": "caddy"}},
+		},
+		strategies: map[string]string{
+			"command": MergeReplace,
+			"ports":   MergeUnique,
+			"volumes": MergePrepend,
+			"env":     MergeReplace,
+		},
+	}
+
+	chart.Includes = nil
+	user.Includes = []*urlConfig{chart}
+	cfg.Paths = []*urlConfig{user}
+
+	require.NoError(t, cfg.merge(t.Context()))
+
+	require.Equal(t, []any{"run"}, cfg.Data["command"])
+	require.Equal(t, []any{"80", "443", "8080"}, cfg.Data["ports"])
+	require.Equal(t, []any{"config", "data"}, cfg.Data["volumes"])
+	require.Equal(t, map[string]any{"A": "3"}, cfg.Data["env"])
+	require.Equal(t, []any{map[string]any{"name": "proxy", "image": "caddy"}, map[string]any{"name": "cron", "image": "cron"}}, cfg.Data["sidecars"])
+
+	entry, ok := cfg.Provenance.Get("command[0]")
+	require.True(t, ok)
+	require.Equal(t, url1.String(), entry.Origin.Source)
+	require.Len(t, entry.Shadowed, 1)
+
+	_, ok = cfg.Provenance.Get("command[1]")
+	require.False(t, ok)
+
+	entry, ok = cfg.Provenance.Get("volumes[1]")
+	require.True(t, ok)
+	require.Equal(t, url2.String(), entry.Origin.Source)
+
+	entry, ok = cfg.Provenance.Get("sidecars[0].image")
+	require.True(t, ok)
+	require.Equal(t, "caddy", entry.Origin.Value)
+	require.Equal(t, "nginx", entry.Shadowed[0].Value)
+
+	require.Equal(t, "unique", cfg.strategies["ports"])
+}
+
+func TestMergeStrategyMismatch(t *testing.T) {
+	m := &merger{source: "config.yaml", strategies: map[string]string{"env": MergeAppend}}
+
+	_, err := m.merge(map[string]any{}, map[string]any{"env": map[string]any{"A": "1"}}, "", "")
+	require.ErrorIs(t, err, ErrMergeStrategy)
+}
@@ -2,7 +2,6 @@
 package octoconfig
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -14,43 +13,68 @@ import (
 	"maps"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
-	"text/template"
+	"sync"
+	"time"
 
-	"dario.cat/mergo"
+	"filippo.io/age"
 	"github.com/go-orb/go-orb/codecs"
 	"github.com/go-orb/go-orb/config"
 	"github.com/go-orb/go-orb/log"
 	"github.com/octocompose/octoctl/pkg/octocache"
+	"github.com/octocompose/octoctl/pkg/octooci"
+	"github.com/octocompose/octoctl/pkg/octosecrets"
 
 	"github.com/hashicorp/go-multierror"
-	"github.com/lithammer/shortuuid/v3"
 )
 
 const schemeFile = "file"
 
 const gpgAsc = ".asc"
 
+// renderedDir is the directory of rendered file templates next to config.json. Rendered files may contain
+// decrypted values, so they don't go to the cache directories.
+const renderedDir = "rendered"
+
 // OctoctlConfig represents the `octoctl` structure of the octoctl config file.
 type OctoctlConfig struct {
-	Operator string   `json:"operator"`
-	Command  []string `json:"command,omitempty"`
+	Operator        string   `json:"operator"`
+	Command         []string `json:"command,omitempty"`
+	StrictTemplates bool     `json:"strictTemplates,omitempty"`
 }
 
-// AbsURL makes the URL absolute if it is relative.
+// AbsURL makes the URL absolute if it is relative, relative URLs in git repositories and OCI artifacts stay in the
+// same repository or artifact.
 func AbsURL(dst *url.URL, src *url.URL) {
-	if !filepath.IsAbs(dst.Path) {
+	if filepath.IsAbs(dst.Path) {
+		return
+	}
+
+	if repo, file, ok := strings.Cut(src.Path, "//"); ok && (octocache.IsGitURL(src) || octooci.IsOCIURL(src)) {
 		dst.Scheme = src.Scheme
+		dst.User = src.User
 		dst.Host = src.Host
-		dir := filepath.Dir(src.Path)
-		dst.Path = filepath.Join(dir, dst.Path)
+		dst.Path = repo + "//" + path.Join(path.Dir(file), dst.Path)
+
+		if dst.RawQuery == "" {
+			dst.RawQuery = src.RawQuery
+		}
+
+		return
 	}
+
+	dst.Scheme = src.Scheme
+	dst.Host = src.Host
+	dir := filepath.Dir(src.Path)
+	dst.Path = filepath.Join(dir, dst.Path)
 }
 
-func templateFile(url *config.URL, projectID string, templateVars map[string]any) (*config.URL, error) {
+// templateFile renders the template file url to a 0600 file in the rendered directory of the project.
+func (c *Config) templateFile(url *config.URL, templateVars map[string]any, source string) (*config.URL, error) {
 	if url.Scheme != schemeFile {
 		return nil, fmt.Errorf("while templating file '%s': only 'file' URLs are supported", url.String())
 	}
@@ -58,7 +82,7 @@ func templateFile(url *config.URL, projectID string, templateVars map[string]any
 	sha256sum := sha256.Sum256([]byte(url.URL.String()))
 	ext := filepath.Ext(url.URL.Path)
 
-	templatePath, err := octocache.Path(projectID, "template", hex.EncodeToString(sha256sum[:16])+ext)
+	templatePath, err := octocache.Path(c.ProjectID, renderedDir, hex.EncodeToString(sha256sum[:16])+ext)
 	if err != nil {
 		return nil, err
 	}
@@ -99,13 +123,13 @@ func templateFile(url *config.URL, projectID string, templateVars map[string]any
 		}
 	}()
 
-	t, err := template.New("template").Parse(string(b))
+	t, err := c.NewTemplate(url.String()).Parse(string(b))
 	if err != nil {
 		return nil, fmt.Errorf("while parsing template: %w", err)
 	}
 
-	buf := &bytes.Buffer{}
-	if err := t.Execute(buf, templateVars); err != nil {
+	buf, err := c.execute(t, templateVars, source)
+	if err != nil {
 		return nil, fmt.Errorf("while executing template: %w", err)
 	}
 
@@ -133,29 +157,73 @@ type configIncludeVersions struct {
 type urlConfig struct {
 	URL      *config.URL           `json:"url"`
 	GPG      *config.URL           `json:"gpg"`
+	Version  string                `json:"version"`
 	Versions configIncludeVersions `json:"versions"`
+	When     string                `json:"when"`
+	Vars     map[string]any        `json:"vars"`
+	Into     string                `json:"into"`
 
 	Cached   *config.URL    `json:"-"`
 	Data     map[string]any `json:"-"`
 	Includes []*urlConfig   `json:"-"`
 	Repo     *Repo          `json:"-"`
+
+	// lines contains the line of each value in the source file.
+	lines map[string]int
+
+	// schemas contains the schemas referenced by the file.
+	schemas []SchemaRef
+
+	// profiles contains the overlays of the `profiles` section by profile name.
+	profiles map[string]map[string]any
+
+	// strategies contains the merge strategy of each path in the source file.
+	strategies map[string]string
+
+	// patches contains the `patches` section.
+	patches []Patch
+
+	// pending is true until the `when` condition of the include holds.
+	pending bool
+
+	// mount is the path the data gets merged at, the `into` paths of the include and its parents joined.
+	mount string
+	// vars contains the vars of the include and its parents.
+	vars map[string]any
+	// instance identifies the mount path and vars, it's empty without both.
+	instance string
 }
 
-// Flatten returns a sequence iterator that yields the urlConfig and all its includes.
+// Flatten returns a sequence iterator that yields the urlConfig and all its includes. An include which is
+// part of a cycle gets yielded once, checkCycles reports the cycle.
 func (u *urlConfig) Flatten() iter.Seq[*urlConfig] {
 	return iter.Seq[*urlConfig](func(yield func(*urlConfig) bool) {
-		if !yield(u) {
-			return
+		u.flatten(nil, yield)
+	})
+}
+
+func (u *urlConfig) flatten(parents []*urlConfig, yield func(*urlConfig) bool) bool {
+	if slices.Contains(parents, u) {
+		return true
+	}
+
+	if !yield(u) {
+		return false
+	}
+
+	parents = append(parents, u)
+
+	for _, include := range u.Includes {
+		if include.pending {
+			continue
 		}
 
-		for _, include := range u.Includes {
-			for subConfig := range include.Flatten() {
-				if !yield(subConfig) {
-					return
-				}
-			}
+		if !include.flatten(parents, yield) {
+			return false
 		}
-	})
+	}
+
+	return true
 }
 
 // FlattenRepo returns a sequence iterator that yields the *RepoFile and all its children.
@@ -172,115 +240,232 @@ func (u *urlConfig) FlattenRepo() iter.Seq[*Repo] {
 }
 
 func (u *urlConfig) String() string {
+	if u.instance != "" {
+		return u.URL.URL.String() + "#" + u.instance
+	}
+
 	return u.URL.URL.String()
 }
 
-// readRepo reads a repository configuration file.
-func (c *Config) readRepo(ctx context.Context, url *config.URL, parent *Repo) error {
+// readRepo reads a repository configuration file, chain contains the URLs of all parents. A repository which
+// has already been read gets returned as is.
+func (c *Config) readRepo(ctx context.Context, include RepoInclude, chain []string) (*Repo, error) {
+	url := include.URL
+
+	tmpRepo, known := c.claimRepo(url)
+	if known {
+		c.logger.Trace("Repository already read", "url", url.String())
+		return tmpRepo, nil
+	}
+
+	chain = append(slices.Clone(chain), url.String())
+
 	c.logger.Trace("Read repository", "url", url.String())
 
-	// Resolve the URL.
-	cached, err := octocache.CachedURL(ctx, c.ProjectID, url, nil, "repos", true)
+	var cached *config.URL
+
+	err := c.fetch(ctx, func(ctx context.Context) error {
+		var err error
+
+		// Resolve the URL.
+		cached, err = octocache.CachedURL(ctx, c.ProjectID, url, nil, "repos", true)
+		if err != nil {
+			return err
+		}
+
+		return c.verify(ctx, url, cached, include.GPG, "repos")
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Read the cached file.
 	data, err := config.Read(cached.URL)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	tmpRepo.lines = yamlLines(cached.Path)
+
+	tmpRepo.strategies, err = readStrategies(url.String(), data, yamlTags(cached.Path))
+	if err != nil {
+		return nil, err
 	}
 
-	tmpRepo := &Repo{}
-	tmpRepo.URL = url
+	tmpRepo.raw = data
 
 	if err := config.Parse(nil, "", data, tmpRepo); err != nil {
-		return fmt.Errorf("while parsing repository '%s': %w", url.String(), err)
+		return nil, fmt.Errorf("while parsing repository '%s': %w", url.String(), err)
 	}
 
-	parent.Children = append(parent.Children, tmpRepo)
+	absSchemaURLs(tmpRepo.Schemas, url.URL)
+
+	err = c.readRepoIncludes(ctx, tmpRepo, tmpRepo.Include, url.URL, chain)
+
+	tmpRepo.Include = nil
 
-	for _, include := range tmpRepo.Include {
+	return tmpRepo, err
+}
+
+// readRepoIncludes reads the repository includes of parent concurrently, the repositories get added to its
+// children in the order of includes.
+func (c *Config) readRepoIncludes(ctx context.Context, parent *Repo, includes []RepoInclude, base *url.URL, chain []string) error {
+	mErr := &multierror.Error{}
+
+	readInclude := func(ctx context.Context, include RepoInclude) (*Repo, error) {
 		// Make the URL absolute if it's a relative URL.
-		AbsURL(include.URL.URL, url.URL)
+		AbsURL(include.URL.URL, base)
 
-		if include.GPG != nil {
-			AbsURL(include.GPG.URL, url.URL)
-		} else {
-			gpg, err := include.URL.Copy()
-			if err != nil {
-				return err
-			}
+		gpg, err := resolveGPG(include.URL, include.GPG, base)
+		if err != nil {
+			return nil, err
+		}
+
+		include.GPG = gpg
 
-			gpg.URL.Path += gpgAsc
-			include.GPG = gpg
+		if err := checkCycle(chain, include.URL); err != nil {
+			return nil, err
 		}
 
-		if err := c.readRepo(ctx, include.URL, tmpRepo); err != nil {
-			return err
+		return c.readRepo(ctx, include, chain)
+	}
+
+	repos := make([]*Repo, len(includes))
+	errs := make([]error, len(includes))
+
+	parallel(len(includes), func(idx int) {
+		if includes[idx].When == "" {
+			repos[idx], errs[idx] = readInclude(ctx, includes[idx])
 		}
+	})
 
-		tmpRepo.Include = nil
+	for idx, include := range includes {
+		if include.When != "" {
+			c.deferRepo(include.When, base.String(), parent, func(ctx context.Context) (*Repo, error) { return readInclude(ctx, include) })
+			continue
+		}
+
+		if repos[idx] != nil {
+			parent.Children = append(parent.Children, repos[idx])
+		}
+
+		if errs[idx] != nil {
+			mErr = multierror.Append(mErr, errs[idx])
+		}
 	}
 
-	return nil
+	return mErr.ErrorOrNil()
 }
 
 func (c *Config) processFileRepo(ctx context.Context, fileConfig *urlConfig) error {
 	mErr := &multierror.Error{}
 
-	for _, repo := range fileConfig.Repo.Include {
-		// Make the URL absolute if it's a relative URL.
-		AbsURL(repo.URL.URL, fileConfig.URL.URL)
+	chain := []string{fileConfig.URL.String()}
+	if err := c.readRepoIncludes(ctx, fileConfig.Repo, fileConfig.Repo.Include, fileConfig.URL.URL, chain); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
 
-		if repo.GPG != nil {
-			AbsURL(repo.GPG.URL, fileConfig.URL.URL)
-		} else {
-			gpg, err := repo.URL.Copy()
-			if err != nil {
-				mErr = multierror.Append(mErr, err)
-				continue
-			}
+	fileConfig.Repo.Include = nil
 
-			gpg.URL.Path += gpgAsc
-			repo.GPG = gpg
-		}
+	absSchemaURLs(fileConfig.Repo.Schemas, fileConfig.URL.URL)
 
-		if err := c.readRepo(ctx, repo.URL, fileConfig.Repo); err != nil {
-			mErr = multierror.Append(mErr, err)
-		}
+	if raw, ok := fileConfig.Data["repos"].(map[string]any); ok {
+		fileConfig.Repo.raw = raw
 	}
 
-	fileConfig.Repo.Include = nil
-
 	delete(fileConfig.Data, "repos")
 
 	return mErr.ErrorOrNil()
 }
 
-// readURL reads the configuration data from a urlConfig's URL.
-func (c *Config) readURL(ctx context.Context, fileConfig *urlConfig) error {
+// claimRepo returns the repository already known for url, otherwise it registers a new one which the caller reads.
+func (c *Config) claimRepo(url *config.URL) (*Repo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if known, ok := c.knownRepos[url.String()]; ok {
+		return known, true
+	}
+
+	repo := &Repo{}
+	repo.URL = url
+	c.knownRepos[url.String()] = repo
+
+	return repo, false
+}
+
+// claimConfig returns the config already known for the instance of include, otherwise it registers include
+// which the caller reads.
+func (c *Config) claimConfig(include *urlConfig) (*urlConfig, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if known, ok := c.knownConfigs[include.String()]; ok {
+		return known, true
+	}
+
+	c.knownConfigs[include.String()] = include
+
+	return include, false
+}
+
+// readURL reads the configuration data from a urlConfig's URL, chain contains the URLs of all parents.
+func (c *Config) readURL(ctx context.Context, fileConfig *urlConfig, chain []string) error {
 	mErr := &multierror.Error{}
 
-	// Resolve the URL.
-	cached, err := octocache.CachedURL(ctx, c.ProjectID, fileConfig.URL, nil, "configs", true)
+	chain = append(slices.Clone(chain), fileConfig.URL.String())
+
+	var cached *config.URL
+
+	err := c.fetch(ctx, func(ctx context.Context) error {
+		var err error
+
+		// Resolve the URL.
+		cached, err = octocache.CachedURL(ctx, c.ProjectID, fileConfig.URL, nil, "configs", true)
+		if err != nil {
+			return err
+		}
+
+		c.logger.Trace("Read file", "original", fileConfig.URL.String(), "cached", cached.URL.String())
+
+		return c.verify(ctx, fileConfig.URL, cached, fileConfig.GPG, "configs")
+	})
 	if err != nil {
 		return err
 	}
 
-	c.logger.Trace("Read file", "original", fileConfig.URL.String(), "cached", cached.URL.String())
-
 	// Read the cached file.
 	data, err := config.Read(cached.URL)
 	if err != nil {
 		return err
 	}
 
+	if err := c.decrypt(fileConfig.URL.String(), data); err != nil {
+		return err
+	}
+
+	if len(fileConfig.vars) > 0 {
+		c.mu.Lock()
+		c.includeVars[fileConfig.instance] = fileConfig.vars
+		c.mu.Unlock()
+
+		bindVars(data, fileConfig.instance)
+	}
+
+	strategies, err := readStrategies(fileConfig.URL.String(), data, yamlTags(cached.Path))
+	if err != nil {
+		return err
+	}
+
 	fileConfig.Cached = cached
 	fileConfig.Data = data
+	fileConfig.lines = yamlLines(cached.Path)
+	fileConfig.strategies = strategies
 
 	fileConfig.Repo = &Repo{}
 	fileConfig.Repo.URL = fileConfig.URL
+	fileConfig.Repo.lines = subLines(fileConfig.lines, "repos")
+	fileConfig.Repo.strategies = subLines(fileConfig.strategies, "repos")
 
 	err = config.Parse(nil, "repos", fileConfig.Data, fileConfig.Repo)
 	if err != nil {
@@ -296,6 +481,23 @@ func (c *Config) readURL(ctx context.Context, fileConfig *urlConfig) error {
 		}
 	}
 
+	if err := config.ParseSlice([]string{}, "schemas", fileConfig.Data, &fileConfig.schemas); err != nil {
+		if !errors.Is(err, config.ErrNoSuchKey) {
+			mErr = multierror.Append(mErr, fmt.Errorf("while parsing schemas '%s': %w", fileConfig.URL.String(), err))
+		}
+	}
+
+	absSchemaURLs(fileConfig.schemas, fileConfig.URL.URL)
+	delete(fileConfig.Data, "schemas")
+
+	if err := readProfiles(fileConfig); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := readPatches(fileConfig); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
 	// Recursively parse the include section if present.
 	var includes []*urlConfig
 
@@ -310,42 +512,160 @@ func (c *Config) readURL(ctx context.Context, fileConfig *urlConfig) error {
 	// Remove the include section from the data.
 	delete(fileConfig.Data, "include")
 
-	// Iterate over the include paths and create URLConfigs for them.
-	for _, include := range includes {
-		// Make the URL absolute if it's a relative URL.
-		AbsURL(include.URL.URL, fileConfig.URL.URL)
+	// Read the includes concurrently, they get added in their order.
+	results := make([][]*urlConfig, len(includes))
+	errs := make([]error, len(includes))
 
-		if include.GPG != nil {
-			AbsURL(include.GPG.URL, fileConfig.URL.URL)
-		} else {
-			gpg, err := include.URL.Copy()
-			if err != nil {
-				mErr = multierror.Append(mErr, err)
-				continue
-			}
+	parallel(len(includes), func(idx int) {
+		if includes[idx].When == "" {
+			results[idx], errs[idx] = c.readInclude(ctx, fileConfig, includes[idx], chain)
+		}
+	})
 
-			gpg.URL.Path += gpgAsc
-			include.GPG = gpg
+	for idx, include := range includes {
+		if include.When != "" {
+			c.deferInclude(fileConfig, include, chain)
+			continue
 		}
 
-		// Add the include to the URLConfig.
-		fileConfig.Includes = append(fileConfig.Includes, include)
+		fileConfig.Includes = append(fileConfig.Includes, results[idx]...)
 
-		// Read the include.
-		if err := c.readURL(ctx, include); err != nil {
-			mErr = multierror.Append(mErr, err)
+		if errs[idx] != nil {
+			mErr = multierror.Append(mErr, errs[idx])
 		}
 	}
 
 	return mErr.ErrorOrNil()
 }
 
+// readInclude reads an include of fileConfig, it returns the configs to add to the includes of fileConfig.
+// Globs and directories expand to one config per file.
+func (c *Config) readInclude(ctx context.Context, fileConfig *urlConfig, include *urlConfig, chain []string) ([]*urlConfig, error) {
+	if include.Version != "" {
+		err := c.fetch(ctx, func(ctx context.Context) error { return c.resolveVersion(ctx, include, fileConfig.URL.URL) })
+		if err != nil {
+			return nil, fmt.Errorf("while resolving include of '%s': %w", fileConfig.URL.String(), err)
+		}
+	}
+
+	if include.URL == nil || include.URL.URL == nil {
+		return nil, fmt.Errorf("include without url in '%s'", fileConfig.URL.String())
+	}
+
+	dir := strings.HasSuffix(include.URL.Path, "/")
+
+	// Make the URL absolute if it's a relative URL.
+	AbsURL(include.URL.URL, fileConfig.URL.URL)
+
+	includes := []*urlConfig{include}
+
+	if dir || octocache.IsPattern(include.URL.Path) {
+		var expanded []*urlConfig
+
+		err := c.fetch(ctx, func(ctx context.Context) error {
+			var err error
+
+			expanded, err = c.expandInclude(ctx, include, dir, chain)
+
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("while expanding include of '%s': %w", fileConfig.URL.String(), err)
+		}
+
+		includes = expanded
+	}
+
+	readOne := func(ctx context.Context, include *urlConfig) (*urlConfig, error) {
+		gpg, err := resolveGPG(include.URL, include.GPG, fileConfig.URL.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		include.GPG = gpg
+
+		if err := checkCycle(chain, include.URL); err != nil {
+			return nil, err
+		}
+
+		if err := instantiate(fileConfig, include); err != nil {
+			return nil, err
+		}
+
+		// Diamond includes are read once per instance, collectConfigs deduplicates them.
+		if known, ok := c.claimConfig(include); ok {
+			c.logger.Trace("Include already read", "url", include.URL.String(), "parent", fileConfig.URL.String())
+			return known, nil
+		}
+
+		// Read the include.
+		return include, c.readURL(ctx, include, chain)
+	}
+
+	configs := make([]*urlConfig, len(includes))
+	errs := make([]error, len(includes))
+
+	parallel(len(includes), func(idx int) {
+		configs[idx], errs[idx] = readOne(ctx, includes[idx])
+	})
+
+	mErr := &multierror.Error{}
+	result := make([]*urlConfig, 0, len(includes))
+
+	for idx, known := range configs {
+		if known != nil {
+			result = append(result, known)
+		}
+
+		if errs[idx] != nil {
+			mErr = multierror.Append(mErr, errs[idx])
+		}
+	}
+
+	return result, mErr.ErrorOrNil()
+}
+
 // Config represents a configuration.
 type Config struct {
 	logger log.Logger
 
 	clearCache bool
 
+	insecureSkipVerify bool
+	keyrings           []string
+
+	strictTemplates bool
+	unresolved      []error
+
+	ageKeyFile    string
+	ageIdentities []age.Identity
+	secretStore   *octosecrets.Store
+
+	profiles  []string
+	environ   []string
+	overrides []setOverride
+
+	// strategies contains the merge strategies of the merged data by path.
+	strategies map[string]string
+
+	concurrency  int
+	fetchTimeout time.Duration
+	// fetchSlots limits the number of concurrent fetches during a run.
+	fetchSlots chan struct{}
+
+	// mu guards the state shared by concurrent reads.
+	mu sync.Mutex
+
+	includeDedupe string
+	knownConfigs  map[string]*urlConfig
+	knownRepos    map[string]*Repo
+
+	// includeVars contains the vars of the include instances by instance.
+	includeVars map[string]map[string]any
+
+	// pending contains the includes whose `when` condition hasn't been evaluated yet.
+	pending []*pendingInclude
+
 	Paths   []*urlConfig
 	Repo    *Repo
 	Octoctl *OctoctlConfig
@@ -359,12 +679,54 @@ type Config struct {
 	Data map[string]any
 
 	ProjectID string
+
+	// Signatures contains the signature verification results of all includes.
+	Signatures []SignatureResult
+
+	// Provenance contains the origin of every merged value.
+	Provenance *Provenance
+
+	// ResolvedVersions contains the versions chosen for includes with a version constraint.
+	ResolvedVersions []ResolvedVersion
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithInsecureSkipVerify disables the signature verification of includes.
+func WithInsecureSkipVerify(skip bool) Option {
+	return func(c *Config) {
+		c.insecureSkipVerify = skip
+	}
+}
+
+// WithIncludeDedupe sets how includes which are included more than once get merged,
+// one of IncludeDedupeFirst, IncludeDedupeLast or IncludeDedupeNone.
+func WithIncludeDedupe(mode string) Option {
+	return func(c *Config) {
+		c.includeDedupe = mode
+	}
+}
+
+// WithKeyrings adds keyring files whose keys are trusted for all includes.
+func WithKeyrings(paths ...string) Option {
+	return func(c *Config) {
+		c.keyrings = append(c.keyrings, paths...)
+	}
 }
 
 // New creates a new configuration from the given paths.
-func New(logger log.Logger, clearCache bool, paths []string, hardcodedData map[string]any) (*Config, error) {
+func New(logger log.Logger, clearCache bool, paths []string, hardcodedData map[string]any, opts ...Option) (*Config, error) {
 	cfg := &Config{logger: logger, Paths: []*urlConfig{}, KnownURLs: map[string]struct{}{}, Repo: &Repo{}, HardcodedData: hardcodedData}
 
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if err := checkDedupe(cfg.includeDedupe); err != nil {
+		return nil, err
+	}
+
 	for _, path := range paths {
 		myURL, err := config.NewURL(path)
 		if err != nil {
@@ -391,7 +753,12 @@ func New(logger log.Logger, clearCache bool, paths []string, hardcodedData map[s
 			continue
 		}
 
-		cfg.Paths = append(cfg.Paths, &urlConfig{URL: myURL})
+		gpg, err := resolveGPG(myURL, nil, myURL.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Paths = append(cfg.Paths, &urlConfig{URL: myURL, GPG: gpg})
 		cfg.KnownURLs[myURL.String()] = struct{}{}
 	}
 
@@ -401,9 +768,25 @@ func New(logger log.Logger, clearCache bool, paths []string, hardcodedData map[s
 	return cfg, nil
 }
 
+// LockPath returns the path of the lockfile, next to the first local config file or in the working directory.
+func (c *Config) LockPath() (string, error) {
+	for _, path := range c.Paths {
+		if path.URL.Scheme == schemeFile {
+			return filepath.Join(filepath.Dir(path.URL.Path), octocache.LockFileName), nil
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(wd, octocache.LockFileName), nil
+}
+
 // Run runs the configuration.
 func (c *Config) Run(ctx context.Context) error {
-	if err := c.ensureProjectID(ctx); err != nil {
+	if err := c.EnsureProjectID(ctx); err != nil {
 		return err
 	}
 
@@ -421,6 +804,10 @@ func (c *Config) Run(ctx context.Context) error {
 		return err
 	}
 
+	if err := c.resolveValues(); err != nil {
+		return err
+	}
+
 	if err := c.applyGlobals(); err != nil {
 		return err
 	}
@@ -437,11 +824,28 @@ func (c *Config) Run(ctx context.Context) error {
 		return err
 	}
 
+	if err := c.applyPatches(); err != nil {
+		return err
+	}
+
+	if err := c.unresolvedErr(); err != nil {
+		return err
+	}
+
+	if err := c.validate(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// ensureProjectID ensures that the projectID is set in the configuration.
-func (c *Config) ensureProjectID(_ context.Context) error {
+// EnsureProjectID sets the projectID from WithProjectID, the `name` of the first configuration file which has one
+// or derives it from the first configuration file.
+func (c *Config) EnsureProjectID(_ context.Context) error {
+	if c.ProjectID != "" {
+		return validateProjectID(c.ProjectID, "--project")
+	}
+
 	for _, cfg := range c.Paths {
 		data, err := config.Read(cfg.URL.URL)
 		if err != nil {
@@ -449,27 +853,28 @@ func (c *Config) ensureProjectID(_ context.Context) error {
 			continue
 		}
 
-		projectID, ok := data["name"]
+		projectID, ok := data["name"].(string)
 		if !ok {
 			continue
 		}
 
-		c.ProjectID, ok = projectID.(string)
-		if !ok {
-			continue
+		if err := validateProjectID(projectID, cfg.URL.String()); err != nil {
+			return err
 		}
 
+		c.ProjectID = projectID
 		c.logger.Debug("Using name from config", "name", c.ProjectID)
 
 		return nil
 	}
 
-	if c.ProjectID == "" {
-		// Generate a projectID if none was found.
-		c.ProjectID = shortuuid.New()
-		c.logger.Debug("Generated a new name", "name", c.ProjectID)
+	if len(c.Paths) == 0 {
+		return fmt.Errorf("%w: no configuration files to derive it from, set a name or use --project", ErrInvalidProjectID)
 	}
 
+	c.ProjectID = deriveProjectID(c.Paths[0].URL.URL)
+	c.logger.Debug("Derived the name from the configuration file", "name", c.ProjectID, "config", c.Paths[0].URL.String())
+
 	return nil
 }
 
@@ -477,40 +882,110 @@ func (c *Config) ensureProjectID(_ context.Context) error {
 func (c *Config) read(ctx context.Context) error {
 	mErr := &multierror.Error{}
 
+	c.knownConfigs = map[string]*urlConfig{}
+	c.knownRepos = map[string]*Repo{}
+	c.includeVars = map[string]map[string]any{}
+
+	c.initFetch()
+
 	for _, path := range c.Paths {
-		if err := c.readURL(ctx, path); err != nil {
+		c.knownConfigs[path.String()] = path
+	}
+
+	// Read the whole include tree concurrently, the Includes of each config keep their order.
+	errs := make([]error, len(c.Paths))
+
+	parallel(len(c.Paths), func(idx int) {
+		errs[idx] = c.readURL(ctx, c.Paths[idx], nil)
+	})
+
+	for _, err := range errs {
+		if err != nil {
 			mErr = multierror.Append(mErr, err)
 		}
 	}
 
+	// Conditional includes get evaluated against everything read without them.
+	if err := c.readPending(ctx); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := c.checkCycles(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	// Concurrent reads record these in the order they finish.
+	slices.SortStableFunc(c.Signatures, func(a, b SignatureResult) int { return strings.Compare(a.URL, b.URL) })
+	slices.SortStableFunc(c.ResolvedVersions, func(a, b ResolvedVersion) int { return strings.Compare(a.URL, b.URL) })
+
 	return mErr.ErrorOrNil()
 }
 
-// mergeRepos reads and merges repos from the config(s).
-func (c *Config) mergeRepos(_ context.Context) error {
-	mErr := &multierror.Error{}
-
+// collectRepos collects all repository files in the proper processing order.
+func (c *Config) collectRepos() []*Repo {
 	repoFiles := []*Repo{}
 	for _, path := range c.Paths {
 		repoFiles = append(repoFiles, slices.Collect(path.FlattenRepo())...)
 	}
 
+	repoFiles = dedupe(repoFiles, c.includeDedupe)
+
+	// Reverse for proper merge priority (first defined has higher priority).
 	slices.Reverse(repoFiles)
 
+	return repoFiles
+}
+
+// mergeRepos reads and merges repos from the config(s).
+func (c *Config) mergeRepos(_ context.Context) error {
+	mErr := &multierror.Error{}
+
+	repoFiles := c.collectRepos()
+
+	// The repos from the config file take precedence over the repo files.
+	configRepos := c.provenance().remove("repos")
+
 	// Merge all repo files.
+	merged := map[string]any{}
+
 	for _, repoFile := range repoFiles {
 		repoFile.Include = nil
 
-		if err := mergo.Merge(c.Repo, repoFile, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+		repoData, err := config.ParseStruct(nil, repoFile)
+		if err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("while parsing repository '%s': %w", repoFile.String(), err))
+			continue
+		}
+
+		m := &merger{provenance: c.provenance(), source: repoFile.URL.String(), lines: repoFile.lines, strategies: repoFile.strategies}
+
+		data, err := m.merge(merged, repoData, "repos", "")
+		if err != nil {
 			mErr = multierror.Append(mErr, err)
 			continue
 		}
+
+		merged = data
 	}
 
+	c.provenance().override(configRepos)
+
 	// Merge the repos from the config file.
-	repoFile := &Repo{}
-	if err := config.Parse(nil, "repos", c.Data, repoFile); err == nil {
-		mergo.Merge(c.Repo, repoFile, mergo.WithOverride, mergo.WithAppendSlice)
+	if configData, ok := c.Data["repos"].(map[string]any); ok {
+		m := &merger{strategies: c.strategies}
+
+		data, err := m.merge(merged, configData, "repos", "repos")
+		if err != nil {
+			mErr = multierror.Append(mErr, err)
+		} else {
+			merged = data
+		}
+	}
+
+	c.Repo = &Repo{}
+	if err := config.Parse(nil, "", merged, c.Repo); err != nil {
+		mErr = multierror.Append(mErr, fmt.Errorf("while parsing repos: %w", err))
+		return mErr.ErrorOrNil()
 	}
 
 	// Template c.Repo
@@ -527,14 +1002,14 @@ func (c *Config) mergeRepos(_ context.Context) error {
 	}
 
 	templateVars := c.TemplateVars()
-	t, err := template.New("template").Parse(string(b))
+	t, err := c.NewTemplate("repos").Parse(string(b))
 	if err != nil {
 		mErr = multierror.Append(mErr, fmt.Errorf("while parsing template: %w", err))
 		return mErr.ErrorOrNil()
 	}
 
-	buf := &bytes.Buffer{}
-	if err := t.Execute(buf, templateVars); err != nil {
+	buf, err := c.execute(t, templateVars, c.sources("repos"))
+	if err != nil {
 		mErr = multierror.Append(mErr, fmt.Errorf("while executing template: %w", err))
 		return mErr.ErrorOrNil()
 	}
@@ -571,6 +1046,8 @@ func (c *Config) collectConfigs() []*urlConfig {
 		configs = append(configs, slices.Collect(path.Flatten())...)
 	}
 
+	configs = dedupe(configs, c.includeDedupe)
+
 	// Reverse for proper merge priority (first defined has higher priority).
 	slices.Reverse(configs)
 
@@ -584,23 +1061,52 @@ func (c *Config) merge(_ context.Context) error {
 
 	var mErr *multierror.Error
 
+	c.Provenance = newProvenance()
+	c.strategies = map[string]string{}
+
 	// Merge hardcoded data first.
-	if err := mergo.Merge(&c.Data, c.HardcodedData, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+	m := &merger{provenance: c.Provenance, source: OriginHardcoded}
+
+	data, err := m.merge(c.Data, c.HardcodedData, "", "")
+	if err != nil {
 		mErr = multierror.Append(mErr, err)
+	} else {
+		c.Data = data
 	}
 
 	for _, cfg := range configs {
 		// Log that we're merging this config.
 		c.logger.Trace("Merging config", "url", cfg.URL.String())
 
-		if err := mergo.Merge(&c.Data, cfg.Data, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+		strategies := mountPaths(cfg, cfg.strategies)
+		m := &merger{provenance: c.Provenance, source: cfg.URL.String(), lines: mountPaths(cfg, cfg.lines), strategies: strategies}
+
+		data, err := m.merge(c.Data, cfg.mountData(cfg.Data), "", "")
+		if err != nil {
 			mErr = multierror.Append(mErr, err)
+			continue
 		}
+
+		c.Data = data
+		maps.Copy(c.strategies, strategies)
+	}
+
+	if err := c.mergeProfiles(configs); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	// The environment takes precedence over all files, command line overrides over the environment.
+	if err := c.applyEnv(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := c.applyOverrides(); err != nil {
+		mErr = multierror.Append(mErr, err)
 	}
 
 	// Load octoctl config.
 	c.Octoctl = &OctoctlConfig{}
-	if err := config.Parse([]string{}, "octoctl", c.Data, c.Octoctl); err != nil {
+	if err := config.Parse([]string{}, "octoctl", c.Data, c.Octoctl); err != nil && !errors.Is(err, config.ErrNoSuchKey) {
 		mErr = multierror.Append(mErr, fmt.Errorf("while parsing octoctl: %w", err))
 	}
 
@@ -670,24 +1176,32 @@ func (c *Config) applyGlobals() error {
 			return fmt.Errorf("service '%s' requires global config '%s', but it was not found", name, servicesSvcConfig.Globals)
 		}
 
-		if err := mergo.Merge(&mergedConfig, svcGlobal, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+		m := &merger{source: joinPath("globals", servicesSvcConfig.Globals), strategies: c.strategies}
+
+		mergedConfig, err := m.merge(mergedConfig, svcGlobal, "", joinPath("globals", servicesSvcConfig.Globals))
+		if err != nil {
 			return err
 		}
 
 		// Then merge in the service configuration so it takes precedence.
 		svcConfig, ok := servicesConfig[name].(map[string]any)
 		if ok {
-			if err := mergo.Merge(&mergedConfig, svcConfig, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+			m.source = joinPath("configs", name)
+
+			if mergedConfig, err = m.merge(mergedConfig, svcConfig, "", joinPath("configs", name)); err != nil {
 				return err
 			}
 		}
 
 		servicesConfig[name] = mergedConfig
+
+		c.provenance().inherit(joinPath("globals", servicesSvcConfig.Globals), joinPath("configs", name))
 	}
 
 	c.Data["configs"] = servicesConfig
 
 	delete(c.Data, "globals")
+	c.provenance().remove("globals")
 
 	return nil
 }
@@ -703,6 +1217,14 @@ func (c *Config) TemplateVars() map[string]any {
 	data["OS"] = runtime.GOOS
 	data["ARCH"] = runtime.GOARCH
 
+	// Add the selected profiles, profile is the one with the highest priority.
+	data["profiles"] = slices.Clone(c.profiles)
+	data["profile"] = ""
+
+	if len(c.profiles) > 0 {
+		data["profile"] = c.profiles[len(c.profiles)-1]
+	}
+
 	// Add environment variables.
 	envData := map[string]any{}
 
@@ -713,6 +1235,11 @@ func (c *Config) TemplateVars() map[string]any {
 
 	data["env"] = envData
 
+	// Add the vars of the include instances, `.vars` in their templates refers to them.
+	if len(c.includeVars) > 0 {
+		data[varsKey] = c.includeVars
+	}
+
 	return data
 }
 
@@ -741,14 +1268,14 @@ func (c *Config) applyServiceTemplates() error {
 			return fmt.Errorf("while marshaling service %s: %w", name, err)
 		}
 
-		t, err := template.New(name).Parse(string(yamlB))
+		t, err := c.NewTemplate(joinPath("services", name)).Parse(string(yamlB))
 		if err != nil {
-			return fmt.Errorf("while parsing template for service %s: %w", name, err)
+			return fmt.Errorf("while parsing template for service %s defined in %s: %w", name, c.sources(joinPath("services", name)), err)
 		}
 
-		buf := &bytes.Buffer{}
-		if err := t.Execute(buf, templateVars); err != nil {
-			return fmt.Errorf("while executing template for service %s: %w", name, err)
+		buf, err := c.execute(t, templateVars, c.sources(joinPath("services", name)))
+		if err != nil {
+			return fmt.Errorf("while executing template for service %s defined in %s: %w", name, c.sources(joinPath("services", name)), err)
 		}
 
 		newSvc := map[string]any{}
@@ -768,12 +1295,15 @@ func (c *Config) processFileTemplates(ctx context.Context) error {
 
 	templateVars := c.TemplateVars()
 
-	repoFiles := []*Repo{}
-	for _, path := range c.Paths {
-		repoFiles = append(repoFiles, slices.Collect(path.FlattenRepo())...)
+	repoFiles := c.collectRepos()
+
+	// repoFile is a file of a repository which gets fetched.
+	type repoFile struct {
+		repo *Repo
+		name string
 	}
 
-	slices.Reverse(repoFiles)
+	files := []repoFile{}
 
 	for _, repo := range repoFiles {
 		for name, operator := range repo.Operators {
@@ -791,31 +1321,52 @@ func (c *Config) processFileTemplates(ctx context.Context) error {
 
 			AbsURL(fileValue.URL.URL, repo.URL.URL)
 
-			cached, err := octocache.CachedURL(ctx, c.ProjectID, fileValue.URL, nil, "files", true)
-			if err != nil {
-				mErr = multierror.Append(mErr, err)
-				continue
-			}
+			files = append(files, repoFile{repo: repo, name: fileName})
+		}
+	}
 
-			fileValue.URL = cached
-			fileValue.URL.Scheme = "file"
-			repo.Files[fileName] = fileValue
+	// Fetch the files concurrently, templates get rendered one after another.
+	cached := make([]*config.URL, len(files))
+	errs := make([]error, len(files))
 
-			if !fileValue.Template {
-				fileValue.Path = cached.URL.Path
-				repo.Files[fileName] = fileValue
-				continue
-			}
+	parallel(len(files), func(idx int) {
+		errs[idx] = c.fetch(ctx, func(ctx context.Context) error {
+			var err error
 
-			templateURL, err := templateFile(fileValue.URL, c.ProjectID, templateVars)
-			if err != nil {
-				mErr = multierror.Append(mErr, err)
-				continue
-			}
+			cached[idx], err = octocache.CachedURL(ctx, c.ProjectID, files[idx].repo.Files[files[idx].name].URL, nil, "files", true)
+
+			return err
+		})
+	})
+
+	for idx, file := range files {
+		if errs[idx] != nil {
+			mErr = multierror.Append(mErr, errs[idx])
+			continue
+		}
+
+		repo, fileName := file.repo, file.name
+		fileValue := repo.Files[fileName]
+
+		fileValue.URL = cached[idx]
+		fileValue.URL.Scheme = "file"
+		repo.Files[fileName] = fileValue
 
-			fileValue.Path = templateURL.Path
+		if !fileValue.Template {
+			fileValue.Path = cached[idx].URL.Path
 			repo.Files[fileName] = fileValue
+
+			continue
 		}
+
+		templateURL, err := c.templateFile(fileValue.URL, templateVars, fmt.Sprintf("repos.files.%s of %s", fileName, repo.URL.String()))
+		if err != nil {
+			mErr = multierror.Append(mErr, err)
+			continue
+		}
+
+		fileValue.Path = templateURL.Path
+		repo.Files[fileName] = fileValue
 	}
 
 	return mErr.ErrorOrNil()
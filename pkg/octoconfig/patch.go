@@ -0,0 +1,235 @@
+package octoconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// PatchRemove removes the selected services.
+const PatchRemove = "remove"
+
+// ErrPatch happens when a patch is invalid or can't be applied.
+var ErrPatch = errors.New("invalid patch")
+
+// PatchSelector selects the services a patch applies to, all given criteria must match.
+type PatchSelector struct {
+	// Services contains globs of service names, one has to match.
+	Services []string `json:"services,omitempty"`
+	// Labels contains labels the service must have.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Origins contains globs of the URLs of files which define the service, one has to match.
+	Origins []string `json:"origins,omitempty"`
+}
+
+// Patch represents an entry of the `patches` section.
+type Patch struct {
+	Select     PatchSelector  `json:"select"`
+	Op         string         `json:"op,omitempty"`
+	JSONPatch  []any          `json:"jsonPatch,omitempty"`
+	MergePatch map[string]any `json:"mergePatch,omitempty"`
+
+	// source is the URL of the file defining the patch.
+	source string
+}
+
+// String returns the source of the patch.
+func (p Patch) String() string {
+	return "patch from " + p.source
+}
+
+// readPatches moves the `patches` section of a configuration file out of its data.
+func readPatches(fileConfig *urlConfig) error {
+	raw, ok := fileConfig.Data["patches"]
+	if !ok {
+		return nil
+	}
+
+	delete(fileConfig.Data, "patches")
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("while parsing patches '%s': %w", fileConfig.URL.String(), err)
+	}
+
+	if err := json.Unmarshal(b, &fileConfig.patches); err != nil {
+		return fmt.Errorf("while parsing patches '%s': %w", fileConfig.URL.String(), err)
+	}
+
+	for idx := range fileConfig.patches {
+		patch := &fileConfig.patches[idx]
+		patch.source = fileConfig.URL.String()
+
+		if patch.Op != "" && patch.Op != PatchRemove {
+			return fmt.Errorf("%w: unknown op '%s' in '%s'", ErrPatch, patch.Op, patch.source)
+		}
+
+		if patch.Op == "" && patch.JSONPatch == nil && patch.MergePatch == nil {
+			return fmt.Errorf("%w: patch %d in '%s' needs op, jsonPatch or mergePatch", ErrPatch, idx, patch.source)
+		}
+	}
+
+	return nil
+}
+
+// applyPatches applies the patches of all configuration files to the services, patches of files with a
+// higher priority get applied last.
+func (c *Config) applyPatches() error {
+	services, ok := c.Data["services"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for _, cfg := range c.collectConfigs() {
+		for _, patch := range cfg.patches {
+			for _, name := range slices.Sorted(maps.Keys(services)) {
+				if !c.selects(patch.Select, name, services[name]) {
+					continue
+				}
+
+				c.logger.Trace("Applying patch", "service", name, "source", patch.source)
+
+				if patch.Op == PatchRemove {
+					delete(services, name)
+					c.provenance().remove(joinPath("services", name))
+
+					continue
+				}
+
+				patched, err := c.patchService(patch, name, services[name])
+				if err != nil {
+					return fmt.Errorf("while applying patch from '%s' to service %s: %w", patch.source, name, err)
+				}
+
+				services[name] = patched
+			}
+		}
+	}
+
+	return nil
+}
+
+// patchService applies the JSON merge patch and the JSON patch of patch to the service name.
+func (c *Config) patchService(patch Patch, name string, service any) (map[string]any, error) {
+	svc, ok := service.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: service %s isn't a map", ErrPatch, name)
+	}
+
+	original, err := normalize(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.MergePatch != nil {
+		mergePatch, err := json.Marshal(patch.MergePatch)
+		if err != nil {
+			return nil, err
+		}
+
+		if doc, err = jsonpatch.MergePatch(doc, mergePatch); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrPatch, err)
+		}
+	}
+
+	if patch.JSONPatch != nil {
+		ops, err := json.Marshal(patch.JSONPatch)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, err := jsonpatch.DecodePatch(ops)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrPatch, err)
+		}
+
+		if doc, err = decoded.Apply(doc); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrPatch, err)
+		}
+	}
+
+	result := map[string]any{}
+	if err := json.Unmarshal(doc, &result); err != nil {
+		return nil, err
+	}
+
+	// Blame the patch for the values it changed.
+	for _, change := range diffAny(joinPath("services", name), original, result, nil) {
+		if change.Type == ChangeRemoved {
+			c.provenance().remove(change.Path)
+			continue
+		}
+
+		c.provenance().set(change.Path, change.New, patch.String())
+	}
+
+	return result, nil
+}
+
+// selects returns true if selector matches the service name.
+func (c *Config) selects(selector PatchSelector, name string, service any) bool {
+	if len(selector.Services) > 0 && !slices.ContainsFunc(selector.Services, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}) {
+		return false
+	}
+
+	if len(selector.Labels) > 0 {
+		labels := serviceLabels(service)
+
+		for key, value := range selector.Labels {
+			if existing, ok := labels[key]; !ok || existing != value {
+				return false
+			}
+		}
+	}
+
+	if len(selector.Origins) > 0 {
+		origins := c.origins(joinPath("services", name))
+
+		return slices.ContainsFunc(selector.Origins, func(pattern string) bool {
+			return slices.ContainsFunc(origins, func(origin string) bool {
+				matched, _ := path.Match(pattern, origin)
+				return matched || pattern == origin
+			})
+		})
+	}
+
+	return true
+}
+
+// serviceLabels returns the labels of a service, given as map or as list of key=value.
+func serviceLabels(service any) map[string]string {
+	result := map[string]string{}
+
+	svc, ok := service.(map[string]any)
+	if !ok {
+		return result
+	}
+
+	switch labels := svc["labels"].(type) {
+	case map[string]any:
+		for key, value := range labels {
+			result[key] = fmt.Sprint(value)
+		}
+	case []any:
+		for _, label := range labels {
+			key, value, _ := strings.Cut(fmt.Sprint(label), "=")
+			result[key] = value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,87 @@
+package octoconfig
+
+import (
+	"testing"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatches(t *testing.T) {
+	cfg := setupTestConfig()
+
+	chartURL, err := config.NewURL("https://charts.example.com/penpot/all.yaml")
+	require.NoError(t, err)
+	userURL, err := config.NewURL("file:///home/user/config.yaml")
+	require.NoError(t, err)
+
+	user := &urlConfig{
+		URL: userURL,
+		Data: map[string]any{
+			"patches": []any{
+				map[string]any{"select": map[string]any{"services": []any{"penpot-exporter"}}, "op": "remove"},
+				map[string]any{
+					"select":     map[string]any{"origins": []any{"https://charts.example.com/penpot/*"}, "labels": map[string]any{"tier": "backend"}},
+					"mergePatch": map[string]any{"environment": map[string]any{"DEBUG": nil, "LOG": "info"}},
+				},
+				map[string]any{
+					"select":    map[string]any{"services": []any{"penpot-*"}},
+					"jsonPatch": []any{map[string]any{"op": "replace", "path": "/image", "value": "mirror/penpot"}},
+				},
+			},
+		},
+	}
+	require.NoError(t, readPatches(user))
+	require.NotContains(t, user.Data, "patches")
+
+	cfg.Paths = []*urlConfig{user}
+	cfg.Data = map[string]any{
+		"services": map[string]any{
+			"penpot-backend": map[string]any{
+				"image":       "penpot/backend",
+				"labels":      []any{"tier=backend"},
+				"environment": map[string]any{"DEBUG": "true", "PORT": 6060},
+			},
+			"penpot-frontend": map[string]any{"image": "penpot/frontend", "labels": map[string]any{"tier": "frontend"}},
+			"penpot-exporter": map[string]any{"image": "penpot/exporter"},
+		},
+	}
+	cfg.provenance().record("", "", cfg.Data, chartURL.String(), nil)
+
+	require.NoError(t, cfg.applyPatches())
+
+	services := cfg.Data["services"].(map[string]any)
+	require.NotContains(t, services, "penpot-exporter")
+	require.Empty(t, cfg.Provenance.Blame("services.penpot-exporter"))
+
+	require.Equal(t, map[string]any{
+		"image":       "mirror/penpot",
+		"labels":      []any{"tier=backend"},
+		"environment": map[string]any{"LOG": "info", "PORT": float64(6060)},
+	}, services["penpot-backend"])
+	require.Equal(t, "mirror/penpot", services["penpot-frontend"].(map[string]any)["image"])
+	require.NotContains(t, services["penpot-frontend"], "environment")
+
+	entry, ok := cfg.Provenance.Get("services.penpot-backend.environment.LOG")
+	require.True(t, ok)
+	require.Equal(t, "patch from file:///home/user/config.yaml", entry.Origin.Source)
+
+	entry, ok = cfg.Provenance.Get("services.penpot-backend.image")
+	require.True(t, ok)
+	require.Equal(t, "patch from file:///home/user/config.yaml", entry.Origin.Source)
+	require.Equal(t, "penpot/backend", entry.Shadowed[0].Value)
+
+	_, ok = cfg.Provenance.Get("services.penpot-backend.environment.DEBUG")
+	require.False(t, ok)
+}
+
+func TestReadPatchesInvalid(t *testing.T) {
+	url, err := config.NewURL("file:///home/user/config.yaml")
+	require.NoError(t, err)
+
+	fileConfig := &urlConfig{URL: url, Data: map[string]any{"patches": []any{map[string]any{"op": "drop"}}}}
+	require.ErrorIs(t, readPatches(fileConfig), ErrPatch)
+
+	fileConfig = &urlConfig{URL: url, Data: map[string]any{"patches": []any{map[string]any{"select": map[string]any{}}}}}
+	require.ErrorIs(t, readPatches(fileConfig), ErrPatch)
+}
@@ -0,0 +1,100 @@
+package octoconfig
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrUnknownProfile happens when a selected profile isn't defined by any configuration file.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// WithProfiles selects profiles, their overlays get merged on top of the configuration in the given order.
+func WithProfiles(profiles ...string) Option {
+	return func(c *Config) {
+		c.profiles = append(c.profiles, profiles...)
+	}
+}
+
+// Profiles returns the selected profiles.
+func (c *Config) Profiles() []string {
+	return c.profiles
+}
+
+// readProfiles moves the `profiles` section of a configuration file out of its data.
+func readProfiles(fileConfig *urlConfig) error {
+	raw, ok := fileConfig.Data["profiles"]
+	if !ok {
+		return nil
+	}
+
+	delete(fileConfig.Data, "profiles")
+
+	profiles, ok := raw.(map[string]any)
+	if !ok {
+		return fmt.Errorf("while parsing profiles '%s': expected a map", fileConfig.URL.String())
+	}
+
+	fileConfig.profiles = map[string]map[string]any{}
+
+	for name, overlay := range profiles {
+		if overlay == nil {
+			fileConfig.profiles[name] = map[string]any{}
+			continue
+		}
+
+		data, ok := overlay.(map[string]any)
+		if !ok {
+			return fmt.Errorf("while parsing profile '%s' of '%s': expected a map", name, fileConfig.URL.String())
+		}
+
+		fileConfig.profiles[name] = data
+	}
+
+	return nil
+}
+
+// mergeProfiles merges the overlays of the selected profiles on top of the merged data.
+func (c *Config) mergeProfiles(configs []*urlConfig) error {
+	mErr := &multierror.Error{}
+
+	for _, profile := range c.profiles {
+		found := false
+
+		for _, cfg := range configs {
+			overlay, ok := cfg.profiles[profile]
+			if !ok {
+				continue
+			}
+
+			found = true
+
+			c.logger.Trace("Merging profile", "profile", profile, "url", cfg.URL.String())
+
+			strategies := mountPaths(cfg, subLines(cfg.strategies, joinPath("profiles", profile)))
+			m := &merger{
+				provenance: c.provenance(),
+				source:     cfg.URL.String(),
+				lines:      mountPaths(cfg, subLines(cfg.lines, joinPath("profiles", profile))),
+				strategies: strategies,
+			}
+
+			data, err := m.merge(c.Data, cfg.mountData(overlay), "", "")
+			if err != nil {
+				mErr = multierror.Append(mErr, fmt.Errorf("while merging profile '%s' of '%s': %w", profile, cfg.URL.String(), err))
+				continue
+			}
+
+			c.Data = data
+			maps.Copy(c.strategies, strategies)
+		}
+
+		if !found {
+			mErr = multierror.Append(mErr, fmt.Errorf("%w '%s', it's not defined in any configuration file", ErrUnknownProfile, profile))
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
@@ -0,0 +1,62 @@
+package octoconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `include:
+  - url: ./base.yaml
+configs:
+  penpot:
+    public_uri: http://localhost
+profiles:
+  prod:
+    configs:
+      penpot:
+        public_uri: https://penpot.example.com
+`,
+		"base.yaml": `configs:
+  penpot:
+    replicas: 1
+profiles:
+  prod:
+    configs:
+      penpot:
+        replicas: 3
+  debug:
+    configs:
+      penpot:
+        replicas: 1
+        debug: true
+`,
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	WithProfiles("debug", "prod")(cfg)
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+
+	penpot := cfg.Data["configs"].(map[string]any)["penpot"].(map[string]any)
+	require.Equal(t, "https://penpot.example.com", penpot["public_uri"])
+	require.Equal(t, 3, penpot["replicas"])
+	require.Equal(t, true, penpot["debug"])
+	require.NotContains(t, cfg.Data, "profiles")
+
+	entry, ok := cfg.Provenance.Get("configs.penpot.public_uri")
+	require.True(t, ok)
+	require.Equal(t, "file://"+dir+"/main.yaml:10", entry.Origin.String())
+
+	require.Equal(t, "prod", cfg.TemplateVars()["profile"])
+
+	cfg = testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	WithProfiles("staging")(cfg)
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.ErrorIs(t, cfg.merge(t.Context()), ErrUnknownProfile)
+}
@@ -0,0 +1,71 @@
+package octoconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxProjectIDLength is the maximum length of a project ID.
+const maxProjectIDLength = 64
+
+// ErrInvalidProjectID happens when the project ID isn't a safe identifier.
+var ErrInvalidProjectID = errors.New("invalid project name")
+
+// projectIDPattern matches project IDs which are safe as path component.
+//
+//nolint:gochecknoglobals
+var projectIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// unsafeProjectChars matches the characters replaced when deriving a project ID.
+//
+//nolint:gochecknoglobals
+var unsafeProjectChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// WithProjectID sets the project ID instead of the `name` of the configuration.
+func WithProjectID(projectID string) Option {
+	return func(c *Config) {
+		c.ProjectID = projectID
+	}
+}
+
+// validateProjectID returns an error if projectID can't be used as directory name, source is where it's set.
+func validateProjectID(projectID string, source string) error {
+	if len(projectID) > maxProjectIDLength || !projectIDPattern.MatchString(projectID) {
+		return fmt.Errorf("%w '%s' in %s: use up to %d letters, digits, '_', '-' and '.', starting with a letter or digit",
+			ErrInvalidProjectID, projectID, source, maxProjectIDLength)
+	}
+
+	return nil
+}
+
+// deriveProjectID returns a stable project ID for the configuration at u, the name of its directory followed
+// by a short hash of the URL, so the same configuration always gets the same cache.
+func deriveProjectID(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+
+	var name string
+	if dir := path.Dir(u.Path); dir != "/" && dir != "." {
+		name = path.Base(dir)
+	} else {
+		name = u.Hostname()
+	}
+
+	name = strings.Trim(unsafeProjectChars.ReplaceAllString(strings.ToLower(name), "-"), "-_")
+	if name == "" {
+		name = "octocompose"
+	}
+
+	const maxNameLength = maxProjectIDLength - 9
+
+	if len(name) > maxNameLength {
+		name = strings.TrimRight(name[:maxNameLength], "-_")
+	}
+
+	return name + "-" + hex.EncodeToString(sum[:4])
+}
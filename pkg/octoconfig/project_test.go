@@ -0,0 +1,64 @@
+package octoconfig
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureProjectID(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"named.yaml":   "name: penpot\n",
+		"unsafe.yaml":  "name: ../../etc\n",
+		"unnamed.yaml": "services: {}\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "named.yaml"))
+	cfg.ProjectID = ""
+	require.NoError(t, cfg.EnsureProjectID(t.Context()))
+	require.Equal(t, "penpot", cfg.ProjectID)
+
+	cfg = testConfigForFile(t, filepath.Join(dir, "unsafe.yaml"))
+	cfg.ProjectID = ""
+	require.ErrorIs(t, cfg.EnsureProjectID(t.Context()), ErrInvalidProjectID)
+
+	// Without a name the ID is derived from the path, it stays the same across runs.
+	cfg = testConfigForFile(t, filepath.Join(dir, "unnamed.yaml"))
+	cfg.ProjectID = ""
+	require.NoError(t, cfg.EnsureProjectID(t.Context()))
+	require.NoError(t, validateProjectID(cfg.ProjectID, "test"))
+
+	derived := cfg.ProjectID
+
+	cfg = testConfigForFile(t, filepath.Join(dir, "unnamed.yaml"))
+	cfg.ProjectID = ""
+	require.NoError(t, cfg.EnsureProjectID(t.Context()))
+	require.Equal(t, derived, cfg.ProjectID)
+
+	// WithProjectID takes precedence over the name.
+	cfg = testConfigForFile(t, filepath.Join(dir, "named.yaml"))
+	WithProjectID("explicit")(cfg)
+	require.NoError(t, cfg.EnsureProjectID(t.Context()))
+	require.Equal(t, "explicit", cfg.ProjectID)
+
+	WithProjectID("a/b")(cfg)
+	require.ErrorIs(t, cfg.EnsureProjectID(t.Context()), ErrInvalidProjectID)
+}
+
+func TestDeriveProjectID(t *testing.T) {
+	derive := func(s string) string {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+
+		return deriveProjectID(u)
+	}
+
+	require.Regexp(t, `^my-project-[0-9a-f]{8}$`, derive("file:///home/me/My%20Project/octocompose.yaml"))
+	require.NotEqual(t, derive("file:///a/x/octocompose.yaml"), derive("file:///b/x/octocompose.yaml"))
+	require.Regexp(t, `^octocompose-[0-9a-f]{8}$`, derive("file:///octocompose.yaml"))
+	require.Regexp(t, `^example-com-[0-9a-f]{8}$`, derive("https://example.com/octocompose.yaml"))
+	require.LessOrEqual(t, len(derive("file:///"+strings.Repeat("a", 100)+"/octocompose.yaml")), maxProjectIDLength)
+}
@@ -0,0 +1,309 @@
+package octoconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Origin sources which are not URLs.
+const (
+	OriginHardcoded = "hardcoded"
+)
+
+// Origin represents the source of a configuration value.
+type Origin struct {
+	Source string `json:"source"`
+	Line   int    `json:"line,omitempty"`
+	Value  any    `json:"value"`
+}
+
+// String returns the source and line of the origin.
+func (o Origin) String() string {
+	if o.Line > 0 {
+		return fmt.Sprintf("%s:%d", o.Source, o.Line)
+	}
+
+	return o.Source
+}
+
+// ProvenanceEntry represents the origin of a leaf value and the lower priority values it shadowed.
+type ProvenanceEntry struct {
+	Path     string   `json:"path"`
+	Origin   Origin   `json:"origin"`
+	Shadowed []Origin `json:"shadowed,omitempty"`
+}
+
+// Provenance tracks the origin of every leaf value of the merged configuration.
+type Provenance struct {
+	entries map[string]*ProvenanceEntry
+	lists   map[string]int
+}
+
+// newProvenance creates an empty Provenance.
+func newProvenance() *Provenance {
+	return &Provenance{entries: map[string]*ProvenanceEntry{}, lists: map[string]int{}}
+}
+
+// provenance returns the provenance of the config, it creates it if required.
+func (c *Config) provenance() *Provenance {
+	if c.Provenance == nil {
+		c.Provenance = newProvenance()
+	}
+
+	return c.Provenance
+}
+
+// isEmptyValue returns true for values which, like in mergo, don't override existing ones.
+func isEmptyValue(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	return reflect.ValueOf(v).IsZero()
+}
+
+// record records all leaves of data below path as originating from source.
+// filePath is the path of data inside the source, it's used to look up lines.
+func (p *Provenance) record(path string, filePath string, data any, source string, lines map[string]int) {
+	switch typed := data.(type) {
+	case map[string]any:
+		for key, value := range typed {
+			p.record(joinPath(path, key), joinPath(filePath, key), value, source, lines)
+		}
+
+		return
+	case []any:
+		// Slices get appended, continue after the existing items.
+		offset := p.lists[path]
+
+		for idx, item := range typed {
+			p.record(fmt.Sprintf("%s[%d]", path, offset+idx), fmt.Sprintf("%s[%d]", filePath, idx), item, source, lines)
+		}
+
+		p.lists[path] = offset + len(typed)
+
+		return
+	}
+
+	origin := Origin{Source: source, Line: lines[filePath], Value: data}
+
+	entry, ok := p.entries[path]
+	if !ok {
+		p.entries[path] = &ProvenanceEntry{Path: path, Origin: origin}
+		return
+	}
+
+	if isEmptyValue(data) {
+		return
+	}
+
+	entry.Shadowed = append(entry.Shadowed, entry.Origin)
+	entry.Origin = origin
+}
+
+// below returns true if path is prefix or a child of it.
+func below(path string, prefix string) bool {
+	if prefix == "" || path == prefix {
+		return true
+	}
+
+	return strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[")
+}
+
+// remove removes all entries below prefix and returns them.
+func (p *Provenance) remove(prefix string) []*ProvenanceEntry {
+	result := []*ProvenanceEntry{}
+
+	for path, entry := range p.entries {
+		if below(path, prefix) {
+			result = append(result, entry)
+			delete(p.entries, path)
+		}
+	}
+
+	for path := range p.lists {
+		if below(path, prefix) {
+			delete(p.lists, path)
+		}
+	}
+
+	return result
+}
+
+// override puts entries on top of the existing ones.
+func (p *Provenance) override(entries []*ProvenanceEntry) {
+	for _, entry := range entries {
+		existing, ok := p.entries[entry.Path]
+		if !ok {
+			p.entries[entry.Path] = entry
+			continue
+		}
+
+		existing.Shadowed = append(existing.Shadowed, existing.Origin)
+		existing.Shadowed = append(existing.Shadowed, entry.Shadowed...)
+		existing.Origin = entry.Origin
+	}
+}
+
+// inherit copies the entries below from to to, existing entries below to shadow them.
+func (p *Provenance) inherit(from string, to string) {
+	for path, entry := range p.entries {
+		if !below(path, from) {
+			continue
+		}
+
+		target := to + strings.TrimPrefix(path, from)
+
+		existing, ok := p.entries[target]
+		if !ok {
+			p.entries[target] = &ProvenanceEntry{Path: target, Origin: entry.Origin, Shadowed: slices.Clone(entry.Shadowed)}
+			continue
+		}
+
+		existing.Shadowed = append(append(slices.Clone(entry.Shadowed), entry.Origin), existing.Shadowed...)
+	}
+}
+
+// Get returns the entry for path.
+func (p *Provenance) Get(path string) (*ProvenanceEntry, bool) {
+	entry, ok := p.entries[path]
+	return entry, ok
+}
+
+// Blame returns the entries for path and all its children sorted by path, an empty path returns all entries.
+func (p *Provenance) Blame(path string) []*ProvenanceEntry {
+	result := []*ProvenanceEntry{}
+
+	for entryPath, entry := range p.entries {
+		if below(entryPath, path) {
+			result = append(result, entry)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b *ProvenanceEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return result
+}
+
+// origins returns the sources of the values below path.
+func (c *Config) origins(path string) []string {
+	sources := []string{}
+
+	for _, entry := range c.provenance().Blame(path) {
+		if !slices.Contains(sources, entry.Origin.Source) {
+			sources = append(sources, entry.Origin.Source)
+		}
+	}
+
+	return sources
+}
+
+// sources returns the sources of the values below path as comma separated list.
+func (c *Config) sources(path string) string {
+	sources := c.origins(path)
+	if len(sources) == 0 {
+		return "unknown source"
+	}
+
+	return strings.Join(sources, ", ")
+}
+
+// yamlLines returns the line of every leaf value in a YAML file, keyed by its path.
+func yamlLines(path string) map[string]int {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return nil
+	}
+
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	result := map[string]int{}
+	walkYAMLLines("", doc.Content[0], result)
+
+	return result
+}
+
+// subLines returns the lines below prefix with prefix removed from their paths.
+func subLines[V any](lines map[string]V, prefix string) map[string]V {
+	result := map[string]V{}
+
+	for path, line := range lines {
+		if path != prefix && below(path, prefix) {
+			result[strings.TrimPrefix(strings.TrimPrefix(path, prefix), ".")] = line
+		}
+	}
+
+	return result
+}
+
+func walkYAMLLines(path string, node *yaml.Node, result map[string]int) {
+	switch node.Kind { //nolint:exhaustive
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			keyPath := joinPath(path, node.Content[idx].Value)
+			result[keyPath] = node.Content[idx].Line
+			walkYAMLLines(keyPath, node.Content[idx+1], result)
+		}
+	case yaml.SequenceNode:
+		for idx, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, idx)
+			result[itemPath] = item.Line
+			walkYAMLLines(itemPath, item, result)
+		}
+	}
+}
+
+// Annotate returns data as YAML with the origin of each value as line comment.
+func Annotate(data map[string]any, provenance *Provenance) ([]byte, error) {
+	normalized, err := normalize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(normalized); err != nil {
+		return nil, err
+	}
+
+	annotateNode("", node, provenance)
+
+	return yaml.Marshal(node)
+}
+
+func annotateNode(path string, node *yaml.Node, provenance *Provenance) {
+	switch node.Kind { //nolint:exhaustive
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			annotateNode(joinPath(path, node.Content[idx].Value), node.Content[idx+1], provenance)
+		}
+	case yaml.SequenceNode:
+		for idx, item := range node.Content {
+			annotateNode(fmt.Sprintf("%s[%d]", path, idx), item, provenance)
+		}
+	default:
+		entry, ok := provenance.Get(path)
+		if !ok {
+			return
+		}
+
+		node.LineComment = entry.Origin.String()
+		if len(entry.Shadowed) > 0 {
+			node.LineComment += fmt.Sprintf(" (shadows %d)", len(entry.Shadowed))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package octoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenanceMerge(t *testing.T) {
+	cfg := setupTestConfig()
+
+	url1, err := config.NewURL("file:///path/to/config1.yaml")
+	require.NoError(t, err)
+	url2, err := config.NewURL("file:///path/to/config2.yaml")
+	require.NoError(t, err)
+
+	config1 := &urlConfig{
+		URL:   url1,
+		Data:  map[string]any{"shared": "value1", "list": []any{"a"}},
+		lines: map[string]int{"shared": 3},
+	}
+
+	config2 := &urlConfig{
+		URL:  url2,
+		Data: map[string]any{"key2": "value2", "shared": "value2", "list": []any{"b"}},
+	}
+
+	cfg.Paths = append(cfg.Paths, config1, config2)
+	require.NoError(t, cfg.merge(t.Context()))
+
+	entry, ok := cfg.Provenance.Get("shared")
+	require.True(t, ok)
+	require.Equal(t, "file:///path/to/config1.yaml:3", entry.Origin.String())
+	require.Equal(t, "value1", entry.Origin.Value)
+	require.Len(t, entry.Shadowed, 1)
+	require.Equal(t, "value2", entry.Shadowed[0].Value)
+
+	// Slices get appended, config2 has the lower priority.
+	entries := cfg.Provenance.Blame("list")
+	require.Len(t, entries, 2)
+	require.Equal(t, url2.String(), entries[0].Origin.Source)
+	require.Equal(t, url1.String(), entries[1].Origin.Source)
+}
+
+func TestProvenanceGlobals(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"configs": map[string]any{
+			"service1": map[string]any{"option1": "config_value1"},
+		},
+		"services": map[string]any{
+			"service1": map[string]any{
+				"octocompose": map[string]any{"config": map[string]any{"globals": "global1"}},
+			},
+		},
+		"globals": map[string]any{
+			"global1": map[string]any{"option1": "global_value", "option2": "global_option2"},
+		},
+	}
+
+	cfg.provenance().record("", "", cfg.Data, "config.yaml", nil)
+	require.NoError(t, cfg.applyGlobals())
+
+	entry, ok := cfg.Provenance.Get("configs.service1.option2")
+	require.True(t, ok)
+	require.Equal(t, "global_option2", entry.Origin.Value)
+
+	entry, ok = cfg.Provenance.Get("configs.service1.option1")
+	require.True(t, ok)
+	require.Equal(t, "config_value1", entry.Origin.Value)
+	require.Len(t, entry.Shadowed, 1)
+	require.Equal(t, "global_value", entry.Shadowed[0].Value)
+
+	require.Empty(t, cfg.Provenance.Blame("globals"))
+}
+
+func TestYAMLLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: test\nconfigs:\n  db:\n    port: 5432\nlist:\n  - a\n"), 0o600))
+
+	lines := yamlLines(path)
+	require.Equal(t, 1, lines["name"])
+	require.Equal(t, 4, lines["configs.db.port"])
+	require.Equal(t, 6, lines["list[0]"])
+
+	require.Equal(t, map[string]int{"port": 4}, subLines(lines, "configs.db"))
+}
+
+func TestAnnotate(t *testing.T) {
+	provenance := newProvenance()
+	provenance.record("", "", map[string]any{"name": "test"}, "config.yaml", map[string]int{"name": 1})
+
+	b, err := Annotate(map[string]any{"name": "test"}, provenance)
+	require.NoError(t, err)
+	require.Equal(t, "name: test # config.yaml:1\n", string(b))
+}
@@ -2,6 +2,7 @@ package octoconfig
 
 import (
 	"iter"
+	"slices"
 
 	"github.com/go-orb/go-orb/config"
 )
@@ -13,32 +14,65 @@ type Repo struct {
 	Operators map[string]RepoBaremetal `json:"operators,omitempty"`
 	Tools     map[string]RepoTool      `json:"tools,omitempty"`
 	Services  map[string]RepoService   `json:"services,omitempty"`
+	Schemas   []SchemaRef              `json:"schemas,omitempty"`
 
 	URL      *config.URL `json:"-"`
 	Children []*Repo     `json:"-"`
+
+	// lines contains the line of each value in the source file.
+	lines map[string]int
+
+	// raw contains the data as written in the source file.
+	raw map[string]any
+
+	// strategies contains the merge strategy of each path in the source file.
+	strategies map[string]string
+
+	// pending is true for the placeholder of an include whose `when` condition doesn't hold yet.
+	pending bool
 }
 
-// Flatten returns a sequence iterator that yields the urlConfig and all its includes.
+// Flatten returns a sequence iterator that yields the repository and all its children. A child which is part
+// of a cycle gets yielded once, checkCycles reports the cycle.
 func (r *Repo) Flatten() iter.Seq[*Repo] {
 	return iter.Seq[*Repo](func(yield func(*Repo) bool) {
-		if !yield(r) {
-			return
+		r.flatten(nil, yield)
+	})
+}
+
+func (r *Repo) flatten(parents []*Repo, yield func(*Repo) bool) bool {
+	if slices.Contains(parents, r) {
+		return true
+	}
+
+	if !yield(r) {
+		return false
+	}
+
+	parents = append(parents, r)
+
+	for _, child := range r.Children {
+		if child.pending {
+			continue
 		}
 
-		for _, include := range r.Children {
-			for subConfig := range include.Flatten() {
-				if !yield(subConfig) {
-					return
-				}
-			}
+		if !child.flatten(parents, yield) {
+			return false
 		}
-	})
+	}
+
+	return true
+}
+
+func (r *Repo) String() string {
+	return r.URL.String()
 }
 
 // RepoInclude represents a repository include.
 type RepoInclude struct {
-	URL *config.URL `json:"url"`
-	GPG *config.URL `json:"gpg"`
+	URL  *config.URL `json:"url"`
+	GPG  *config.URL `json:"gpg"`
+	When string      `json:"when,omitempty"`
 }
 
 // RepoFileEntry represents a file entry in the repository.
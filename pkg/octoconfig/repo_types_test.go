@@ -49,7 +49,7 @@ func TestRepoFileUnmarshal(t *testing.T) {
 	require.Equal(t, "refs/tags/v0.0.1",
 		baremetalOperator.Source.Ref)
 	require.Len(t, baremetalOperator.Source.BuildCmds, 1)
-	require.Equal(t, "dist/{{OS}}/{{ARCH}}/operator-baremetal",
+	require.Equal(t, "dist/{{.OS}}/{{.ARCH}}/operator-baremetal",
 		baremetalOperator.Source.Binary)
 
 	// Tool
@@ -0,0 +1,386 @@
+package octoconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octocache"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Builtin schema URLs, they are never fetched.
+const (
+	schemaBuiltinConfig = "https://octocompose.dev/schemas/octoctl/config.json"
+	schemaBuiltinRepo   = "https://octocompose.dev/schemas/octoctl/repo.json"
+)
+
+// SchemaRef references a JSON Schema the merged configuration gets validated against.
+type SchemaRef struct {
+	URL *config.URL `json:"url"`
+}
+
+// absSchemaURLs makes the URLs of refs absolute relative to base.
+func absSchemaURLs(refs []SchemaRef, base *url.URL) {
+	for _, ref := range refs {
+		if ref.URL != nil && ref.URL.URL != nil {
+			AbsURL(ref.URL.URL, base)
+		}
+	}
+}
+
+// Violation represents a single schema violation.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Schema  string `json:"schema"`
+	Origin  string `json:"origin,omitempty"`
+}
+
+// String returns the human readable representation of the violation.
+func (v Violation) String() string {
+	if v.Origin == "" {
+		return fmt.Sprintf("%s: %s (%s)", v.Path, v.Message, v.Schema)
+	}
+
+	return fmt.Sprintf("%s: %s (%s, set in %s)", v.Path, v.Message, v.Schema, v.Origin)
+}
+
+// ValidationError happens when the configuration doesn't match its schemas.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		lines = append(lines, "  "+violation.String())
+	}
+
+	return fmt.Sprintf("configuration doesn't match its schemas:\n%s", strings.Join(lines, "\n"))
+}
+
+//nolint:gochecknoglobals
+var configURLType = reflect.TypeOf(config.URL{})
+
+// typeSchema returns a JSON Schema for the JSON representation of t.
+func typeSchema(t reflect.Type) map[string]any {
+	if t == configURLType {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.Pointer:
+		return nullable(typeSchema(t.Elem()))
+	case reflect.Struct:
+		properties := map[string]any{}
+
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = typeSchema(field.Type)
+		}
+
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	case reflect.Map:
+		return nullable(map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())})
+	case reflect.Slice:
+		return nullable(map[string]any{"type": "array", "items": typeSchema(t.Elem())})
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// nullable allows null in addition to the type of schema.
+func nullable(schema map[string]any) map[string]any {
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []any{t, "null"}
+	}
+
+	return schema
+}
+
+// BuiltinSchemas returns the builtin schemas for the `octoctl` section and for repository files.
+func BuiltinSchemas() (map[string]any, map[string]any) {
+	configSchema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"octoctl": typeSchema(reflect.TypeOf(OctoctlConfig{})),
+		},
+	}
+
+	return configSchema, typeSchema(reflect.TypeOf(Repo{}))
+}
+
+// toJSONValue converts v to the value representation of the jsonschema package.
+func toJSONValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonschema.UnmarshalJSON(bytes.NewReader(b))
+}
+
+// pointerToPath converts a JSON pointer location into a config path.
+func pointerToPath(location []string) string {
+	path := ""
+
+	for _, part := range location {
+		if _, err := strconv.Atoi(part); err == nil {
+			path = fmt.Sprintf("%s[%s]", path, part)
+			continue
+		}
+
+		path = joinPath(path, part)
+	}
+
+	return path
+}
+
+// pointer returns the JSON pointer of a location.
+func pointer(location []string) string {
+	escaped := make([]string, 0, len(location))
+	for _, part := range location {
+		escaped = append(escaped, strings.ReplaceAll(strings.ReplaceAll(part, "~", "~0"), "/", "~1"))
+	}
+
+	return "/" + strings.Join(escaped, "/")
+}
+
+// leafErrors returns the leaf errors of a validation error.
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+
+	result := []*jsonschema.ValidationError{}
+	for _, cause := range err.Causes {
+		result = append(result, leafErrors(cause)...)
+	}
+
+	return result
+}
+
+// schemaValidator compiles schemas and collects the violations of all validations.
+type schemaValidator struct {
+	compiler *jsonschema.Compiler
+	printer  *message.Printer
+	schemas  map[string]*jsonschema.Schema
+
+	violations []Violation
+}
+
+func newSchemaValidator() *schemaValidator {
+	return &schemaValidator{
+		compiler: jsonschema.NewCompiler(),
+		printer:  message.NewPrinter(language.English),
+		schemas:  map[string]*jsonschema.Schema{},
+	}
+}
+
+// add compiles the schema doc and registers it as url.
+func (v *schemaValidator) add(url string, doc any) error {
+	jsonDoc, err := toJSONValue(doc)
+	if err != nil {
+		return fmt.Errorf("while converting schema '%s': %w", url, err)
+	}
+
+	if err := v.compiler.AddResource(url, jsonDoc); err != nil {
+		return fmt.Errorf("while adding schema '%s': %w", url, err)
+	}
+
+	schema, err := v.compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("while compiling schema '%s': %w", url, err)
+	}
+
+	v.schemas[url] = schema
+
+	return nil
+}
+
+// addViolation records a violation at location.
+func (v *schemaValidator) addViolation(url string, location []string, message string, origin func(path string) string) {
+	v.violations = append(v.violations, Violation{
+		Path:    pointer(location),
+		Message: message,
+		Schema:  url,
+		Origin:  origin(pointerToPath(location)),
+	})
+}
+
+// validate validates instance against the schema url, origin returns the origin of the value at a config path.
+func (v *schemaValidator) validate(url string, instance any, origin func(path string) string) error {
+	schema, ok := v.schemas[url]
+	if !ok {
+		return fmt.Errorf("unknown schema '%s'", url)
+	}
+
+	err := schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError) //nolint:errorlint
+	if !ok {
+		return fmt.Errorf("while validating against schema '%s': %w", url, err)
+	}
+
+	for _, leaf := range leafErrors(validationErr) {
+		// Report unknown properties on their own, they usually come from different files.
+		if additional, ok := leaf.ErrorKind.(*kind.AdditionalProperties); ok {
+			for _, property := range additional.Properties {
+				location := append(slices.Clone(leaf.InstanceLocation), property)
+				v.addViolation(url, location, "additional property not allowed", origin)
+			}
+
+			continue
+		}
+
+		v.addViolation(url, leaf.InstanceLocation, leaf.ErrorKind.LocalizedString(v.printer), origin)
+	}
+
+	return nil
+}
+
+// origin returns the source and line of the value at path in the repository file.
+func (r *Repo) origin(path string) string {
+	if line, ok := r.lines[path]; ok {
+		return fmt.Sprintf("%s:%d", r.String(), line)
+	}
+
+	return r.String()
+}
+
+// originOf returns the origin of the value at path, or of the first value below it.
+func (c *Config) originOf(path string) string {
+	if entry, ok := c.provenance().Get(path); ok {
+		return entry.Origin.String()
+	}
+
+	if entries := c.provenance().Blame(path); len(entries) > 0 {
+		return entries[0].Origin.Source
+	}
+
+	return ""
+}
+
+// collectSchemas returns the URLs of all schemas referenced by the config files and repos.
+func (c *Config) collectSchemas() []*config.URL {
+	result := []*config.URL{}
+
+	for _, cfg := range c.collectConfigs() {
+		for _, ref := range cfg.schemas {
+			if ref.URL != nil {
+				result = append(result, ref.URL)
+			}
+		}
+	}
+
+	if c.Repo != nil {
+		for _, ref := range c.Repo.Schemas {
+			if ref.URL != nil {
+				result = append(result, ref.URL)
+			}
+		}
+	}
+
+	return dedupe(result, IncludeDedupeFirst)
+}
+
+// validate validates the merged configuration against the builtin and all referenced schemas.
+func (c *Config) validate(ctx context.Context) error {
+	validator := newSchemaValidator()
+	configSchema, repoSchema := BuiltinSchemas()
+
+	if err := validator.add(schemaBuiltinConfig, configSchema); err != nil {
+		return err
+	}
+
+	if err := validator.add(schemaBuiltinRepo, repoSchema); err != nil {
+		return err
+	}
+
+	// Repositories get validated as written, the merged repos lose unknown keys.
+	for _, repo := range c.collectRepos() {
+		if repo.raw == nil {
+			continue
+		}
+
+		instance, err := toJSONValue(repo.raw)
+		if err != nil {
+			return fmt.Errorf("while converting repository '%s': %w", repo.String(), err)
+		}
+
+		if err := validator.validate(schemaBuiltinRepo, instance, repo.origin); err != nil {
+			return err
+		}
+	}
+
+	instance, err := toJSONValue(c.Data)
+	if err != nil {
+		return fmt.Errorf("while converting the configuration: %w", err)
+	}
+
+	if err := validator.validate(schemaBuiltinConfig, instance, c.originOf); err != nil {
+		return err
+	}
+
+	for _, schemaURL := range c.collectSchemas() {
+		cached, err := octocache.CachedURL(ctx, c.ProjectID, schemaURL, nil, "schemas", true)
+		if err != nil {
+			return fmt.Errorf("while fetching schema '%s': %w", schemaURL.String(), err)
+		}
+
+		doc, err := config.Read(cached.URL)
+		if err != nil {
+			return fmt.Errorf("while reading schema '%s': %w", schemaURL.String(), err)
+		}
+
+		if err := validator.add(schemaURL.String(), doc); err != nil {
+			return err
+		}
+
+		if err := validator.validate(schemaURL.String(), instance, c.originOf); err != nil {
+			return err
+		}
+	}
+
+	if len(validator.violations) > 0 {
+		return &ValidationError{Violations: validator.violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package octoconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `schemas:
+  - url: ./schema.json
+include:
+  - url: ./include.yaml
+repos:
+  operators:
+    docker:
+      sources: {}
+`,
+		"include.yaml": "configs:\n  postgres:\n    port: \"5432\"\n",
+		"schema.json": `{
+  "type": "object",
+  "properties": {
+    "configs": {
+      "type": "object",
+      "properties": {
+        "postgres": {
+          "type": "object",
+          "properties": {"port": {"type": "integer"}}
+        }
+      }
+    }
+  }
+}`,
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+
+	err := cfg.validate(t.Context())
+
+	var validationErr *ValidationError
+
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Violations, 2)
+
+	repoViolation := validationErr.Violations[0]
+	require.Equal(t, "/operators/docker/sources", repoViolation.Path)
+	require.Equal(t, schemaBuiltinRepo, repoViolation.Schema)
+	require.Equal(t, "file://"+dir+"/main.yaml:8", repoViolation.Origin)
+
+	configViolation := validationErr.Violations[1]
+	require.Equal(t, "/configs/postgres/port", configViolation.Path)
+	require.Equal(t, "file://"+dir+"/schema.json", configViolation.Schema)
+	require.Equal(t, "file://"+dir+"/include.yaml:3", configViolation.Origin)
+}
+
+func TestBuiltinSchemas(t *testing.T) {
+	configSchema, repoSchema := BuiltinSchemas()
+
+	validator := newSchemaValidator()
+	require.NoError(t, validator.add(schemaBuiltinConfig, configSchema))
+	require.NoError(t, validator.add(schemaBuiltinRepo, repoSchema))
+
+	instance, err := toJSONValue(map[string]any{"octoctl": map[string]any{"operator": "docker", "commands": []string{"up"}}})
+	require.NoError(t, err)
+
+	require.NoError(t, validator.validate(schemaBuiltinConfig, instance, func(string) string { return "" }))
+	require.Len(t, validator.violations, 1)
+	require.Equal(t, "/octoctl/commands", validator.violations[0].Path)
+	require.Equal(t, "additional property not allowed", validator.violations[0].Message)
+}
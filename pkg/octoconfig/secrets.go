@@ -0,0 +1,113 @@
+package octoconfig
+
+import (
+	"fmt"
+	"text/template"
+
+	"filippo.io/age"
+	"github.com/octocompose/octoctl/pkg/octosecrets"
+)
+
+// WithAgeKeyFile sets the age key file used to decrypt encrypted values.
+func WithAgeKeyFile(path string) Option {
+	return func(c *Config) {
+		c.ageKeyFile = path
+	}
+}
+
+// identities loads the age identities on first use.
+func (c *Config) identities() ([]age.Identity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ageIdentities != nil {
+		return c.ageIdentities, nil
+	}
+
+	identities, err := octosecrets.LoadIdentities(c.ageKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c.ageIdentities = identities
+
+	return identities, nil
+}
+
+// decrypt decrypts the encrypted values of data read from source in memory, the cache keeps the encrypted file.
+func (c *Config) decrypt(source string, data map[string]any) error {
+	if !octosecrets.Contains(data) {
+		return nil
+	}
+
+	identities, err := c.identities()
+	if err != nil {
+		return err
+	}
+
+	if _, err := octosecrets.Decrypt(data, identities); err != nil {
+		return fmt.Errorf("while decrypting '%s': %w", source, err)
+	}
+
+	c.logger.Debug("Decrypted secrets", "url", source)
+
+	return nil
+}
+
+// GeneratedSecrets returns the generated secrets of the project, it opens them on first use.
+func (c *Config) GeneratedSecrets() (*octosecrets.Store, error) {
+	if c.secretStore != nil {
+		return c.secretStore, nil
+	}
+
+	store, err := octosecrets.OpenStore(c.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.secretStore = store
+
+	return store, nil
+}
+
+// secretFuncs returns the template functions which generate persistent secrets.
+func (c *Config) secretFuncs() template.FuncMap {
+	return template.FuncMap{
+		"secret": func(name string, length ...int) (string, error) {
+			store, err := c.GeneratedSecrets()
+			if err != nil {
+				return "", err
+			}
+
+			if len(length) == 0 {
+				return store.Secret(name, octosecrets.DefaultSecretLength)
+			}
+
+			return store.Secret(name, length[0])
+		},
+		"uuid": func(name string) (string, error) {
+			store, err := c.GeneratedSecrets()
+			if err != nil {
+				return "", err
+			}
+
+			return store.UUID(name)
+		},
+		"rsaKey": func(name string) (string, error) {
+			store, err := c.GeneratedSecrets()
+			if err != nil {
+				return "", err
+			}
+
+			return store.RSAKey(name)
+		},
+		"rsaPublicKey": func(name string) (string, error) {
+			store, err := c.GeneratedSecrets()
+			if err != nil {
+				return "", err
+			}
+
+			return store.RSAPublicKey(name)
+		},
+	}
+}
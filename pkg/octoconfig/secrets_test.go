@@ -0,0 +1,94 @@
+package octoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/octocompose/octoctl/pkg/octosecrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEncrypted(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	encrypted, err := octosecrets.EncryptValue("hunter2", []age.Recipient{identity.Recipient()})
+	require.NoError(t, err)
+
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": "configs:\n  smtp:\n    password: " + encrypted + "\n",
+	})
+
+	keyFile := filepath.Join(dir, "age.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0o600))
+
+	t.Setenv(octosecrets.EnvKey, "")
+	t.Setenv(octosecrets.EnvKeyFile, "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	require.ErrorIs(t, cfg.read(t.Context()), octosecrets.ErrNoIdentity)
+
+	cfg = testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	WithAgeKeyFile(keyFile)(cfg)
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+	require.Equal(t, "hunter2", cfg.Data["configs"].(map[string]any)["smtp"].(map[string]any)["password"])
+}
+
+func TestRenderedSecretsStayOutOfTheCache(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	encrypted, err := octosecrets.EncryptValue("hunter2", []age.Recipient{identity.Recipient()})
+	require.NoError(t, err)
+
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml":      "configs:\n  smtp:\n    password: " + encrypted + "\nrepos:\n  include:\n    - url: ./repo.yaml\n",
+		"repo.yaml":      "files:\n  smtp.conf:\n    url: ./smtp.conf.tmpl\n    template: true\n",
+		"smtp.conf.tmpl": "password={{ .configs.smtp.password }}\n",
+	})
+
+	keyFile := filepath.Join(dir, "age.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0o600))
+
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	WithAgeKeyFile(keyFile)(cfg)
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+	require.NoError(t, cfg.resolveValues())
+	require.NoError(t, cfg.processFileTemplates(t.Context()))
+
+	rendered := cfg.collectRepos()[0].Files["smtp.conf"].Path
+
+	b, err := os.ReadFile(rendered)
+	require.NoError(t, err)
+	require.Equal(t, "password=hunter2\n", string(b))
+
+	info, err := os.Stat(rendered)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Plaintext only ends up in the rendered directory, not in the cache directories.
+	require.NoError(t, filepath.WalkDir(cacheDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		if strings.Contains(string(b), "hunter2") {
+			require.Equal(t, renderedDir, filepath.Base(filepath.Dir(path)), path)
+		}
+
+		return nil
+	}))
+}
@@ -0,0 +1,299 @@
+package octoconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Kinds of command line overrides, they get applied in this order.
+const (
+	SetJSON  = "set-json"
+	SetFile  = "set-file"
+	SetValue = "set"
+)
+
+// setOverride represents a single `path=value` command line override.
+type setOverride struct {
+	kind string
+	expr string
+}
+
+// WithSet adds `path=value` overrides of kind, they take precedence over all configuration files.
+func WithSet(kind string, exprs ...string) Option {
+	return func(c *Config) {
+		for _, expr := range exprs {
+			c.overrides = append(c.overrides, setOverride{kind: kind, expr: expr})
+		}
+	}
+}
+
+// parseSetPath parses a path like `services.penpot.ports[0]` into map keys (string) and list indices (int).
+// A dot inside a key can be escaped with a backslash.
+func parseSetPath(path string) ([]any, error) {
+	keys := []any{}
+	current := strings.Builder{}
+	hasKey := false
+
+	flush := func() {
+		if hasKey {
+			keys = append(keys, current.String())
+		}
+
+		current.Reset()
+
+		hasKey = false
+	}
+
+	for idx := 0; idx < len(path); idx++ {
+		switch path[idx] {
+		case '\\':
+			if idx+1 < len(path) {
+				idx++
+			}
+
+			current.WriteByte(path[idx])
+
+			hasKey = true
+		case '.':
+			flush()
+		case '[':
+			flush()
+
+			end := strings.IndexByte(path[idx:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("missing ']' in path '%s'", path)
+			}
+
+			index, err := strconv.Atoi(path[idx+1 : idx+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid list index '%s' in path '%s'", path[idx+1:idx+end], path)
+			}
+
+			keys = append(keys, index)
+			idx += end
+		default:
+			current.WriteByte(path[idx])
+
+			hasKey = true
+		}
+	}
+
+	flush()
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty path '%s'", path)
+	}
+
+	if _, ok := keys[0].(string); !ok {
+		return nil, fmt.Errorf("path '%s' must start with a key", path)
+	}
+
+	return keys, nil
+}
+
+// setPathString returns the provenance path of keys.
+func setPathString(keys []any) string {
+	path := ""
+
+	for _, key := range keys {
+		switch typed := key.(type) {
+		case int:
+			path = fmt.Sprintf("%s[%d]", path, typed)
+		case string:
+			path = joinPath(path, typed)
+		}
+	}
+
+	return path
+}
+
+// parseSetValue parses a `--set` value, it supports null, booleans, integers and `{a,b}` lists.
+func parseSetValue(raw string) any {
+	if strings.HasPrefix(raw, "{") && strings.HasSuffix(raw, "}") {
+		result := []any{}
+
+		inner := raw[1 : len(raw)-1]
+		if inner == "" {
+			return result
+		}
+
+		for _, item := range strings.Split(inner, ",") {
+			result = append(result, parseSetValue(item))
+		}
+
+		return result
+	}
+
+	return parseScalar(raw)
+}
+
+// parseScalar parses null, booleans and integers, everything else stays a string. Only integers which
+// format back to raw get converted, so values like `007`, `+1` or `1.10` keep their exact text.
+func parseScalar(raw string) any {
+	switch raw {
+	case "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if i, err := strconv.Atoi(raw); err == nil && strconv.Itoa(i) == raw {
+		return i
+	}
+
+	return raw
+}
+
+// parse returns the keys and the value of the override.
+func (o setOverride) parse() ([]any, any, error) {
+	path, raw, ok := strings.Cut(o.expr, "=")
+	if !ok {
+		return nil, nil, fmt.Errorf("--%s '%s': expected path=value", o.kind, o.expr)
+	}
+
+	keys, err := parseSetPath(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--%s '%s': %w", o.kind, o.expr, err)
+	}
+
+	switch o.kind {
+	case SetJSON:
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, nil, fmt.Errorf("--%s '%s': %w", o.kind, o.expr, err)
+		}
+
+		return keys, value, nil
+	case SetFile:
+		b, err := os.ReadFile(raw) //nolint:gosec
+		if err != nil {
+			return nil, nil, fmt.Errorf("--%s '%s': %w", o.kind, o.expr, err)
+		}
+
+		return keys, string(b), nil
+	default:
+		return keys, parseSetValue(raw), nil
+	}
+}
+
+// setIn sets value at keys below current and returns the new current value.
+// Maps get merged into existing maps, everything else replaces the existing value.
+func setIn(current any, keys []any, value any) (any, error) {
+	if len(keys) == 0 {
+		src, srcOK := value.(map[string]any)
+		dst, dstOK := current.(map[string]any)
+
+		if !srcOK || !dstOK {
+			return value, nil
+		}
+
+		for key, item := range src {
+			merged, err := setIn(dst[key], nil, item)
+			if err != nil {
+				return nil, err
+			}
+
+			dst[key] = merged
+		}
+
+		return dst, nil
+	}
+
+	switch key := keys[0].(type) {
+	case int:
+		list, ok := current.([]any)
+		if !ok && current != nil {
+			return nil, fmt.Errorf("[%d]: not a list", key)
+		}
+
+		for len(list) <= key {
+			list = append(list, nil)
+		}
+
+		item, err := setIn(list[key], keys[1:], value)
+		if err != nil {
+			return nil, fmt.Errorf("[%d]%w", key, err)
+		}
+
+		list[key] = item
+
+		return list, nil
+	case string:
+		m, ok := current.(map[string]any)
+		if !ok && current != nil {
+			return nil, fmt.Errorf(".%s: not a map", key)
+		}
+
+		if m == nil {
+			m = map[string]any{}
+		}
+
+		item, err := setIn(m[key], keys[1:], value)
+		if err != nil {
+			return nil, fmt.Errorf(".%s%w", key, err)
+		}
+
+		m[key] = item
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("invalid key '%v'", keys[0])
+}
+
+// set records value at path as set by source, the values it replaces get shadowed.
+func (p *Provenance) set(path string, value any, source string) {
+	if m, ok := value.(map[string]any); ok {
+		for key, item := range m {
+			p.set(joinPath(path, key), item, source)
+		}
+
+		return
+	}
+
+	replaced := p.remove(path)
+
+	p.record(path, "", value, source, nil)
+
+	p.shadow(replaced)
+}
+
+// applyOverrides applies the command line overrides to the merged data.
+func (c *Config) applyOverrides() error {
+	mErr := &multierror.Error{}
+
+	for _, kind := range []string{SetJSON, SetFile, SetValue} {
+		for _, override := range c.overrides {
+			if override.kind != kind {
+				continue
+			}
+
+			keys, value, err := override.parse()
+			if err != nil {
+				mErr = multierror.Append(mErr, err)
+				continue
+			}
+
+			data, err := setIn(c.Data, keys, value)
+			if err != nil {
+				mErr = multierror.Append(mErr, fmt.Errorf("--%s '%s': %w", override.kind, override.expr, err))
+				continue
+			}
+
+			// parseSetPath ensures the first key is a map key.
+			c.Data = data.(map[string]any) //nolint:errcheck
+
+			path := setPathString(keys)
+			c.provenance().set(path, value, fmt.Sprintf("--%s %s", override.kind, path))
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
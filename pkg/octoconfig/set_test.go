@@ -0,0 +1,75 @@
+package octoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSetPath(t *testing.T) {
+	keys, err := parseSetPath(`services.penpot.ports[1].labels.traefik\.enable`)
+	require.NoError(t, err)
+	require.Equal(t, []any{"services", "penpot", "ports", 1, "labels", "traefik.enable"}, keys)
+	require.Equal(t, `services.penpot.ports[1].labels["traefik.enable"]`, setPathString(keys))
+
+	_, err = parseSetPath("[0]")
+	require.Error(t, err)
+
+	_, err = parseSetPath("ports[x]")
+	require.Error(t, err)
+}
+
+func TestParseSetValue(t *testing.T) {
+	require.Equal(t, 5432, parseSetValue("5432"))
+	require.Equal(t, -1, parseSetValue("-1"))
+	require.Equal(t, true, parseSetValue("true"))
+	require.Nil(t, parseSetValue("null"))
+
+	// Values which don't round-trip stay strings.
+	for _, raw := range []string{"1.10", "1.5", "1e5", "007", "+1", "-0", "NaN", "Inf", "0x10"} {
+		require.Equal(t, raw, parseSetValue(raw))
+	}
+
+	require.Equal(t, "https://x", parseSetValue("https://x"))
+	require.Equal(t, []any{"a", 1}, parseSetValue("{a,1}"))
+}
+
+func TestApplyOverrides(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "motd.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello\n"), 0o600))
+
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"configs": map[string]any{
+			"penpot": map[string]any{"public_uri": "http://localhost", "ports": []any{"80", "443"}},
+		},
+	}
+	cfg.provenance().record("", "", cfg.Data, "config.yaml", nil)
+
+	WithSet(SetValue, "configs.penpot.public_uri=https://x", "configs.penpot.ports[1]=8443")(cfg)
+	WithSet(SetJSON, `configs.penpot.extra={"debug":true}`)(cfg)
+	WithSet(SetFile, "configs.penpot.motd="+file)(cfg)
+
+	require.NoError(t, cfg.applyOverrides())
+
+	penpot := cfg.Data["configs"].(map[string]any)["penpot"].(map[string]any)
+	require.Equal(t, "https://x", penpot["public_uri"])
+	require.Equal(t, []any{"80", 8443}, penpot["ports"])
+	require.Equal(t, map[string]any{"debug": true}, penpot["extra"])
+	require.Equal(t, "hello\n", penpot["motd"])
+
+	entry, ok := cfg.Provenance.Get("configs.penpot.public_uri")
+	require.True(t, ok)
+	require.Equal(t, "--set configs.penpot.public_uri", entry.Origin.Source)
+	require.Len(t, entry.Shadowed, 1)
+	require.Equal(t, "http://localhost", entry.Shadowed[0].Value)
+
+	entry, ok = cfg.Provenance.Get("configs.penpot.extra.debug")
+	require.True(t, ok)
+	require.Equal(t, "--set-json configs.penpot.extra", entry.Origin.Source)
+
+	WithSet(SetValue, "configs.penpot.public_uri.host=x")(cfg)
+	require.Error(t, cfg.applyOverrides())
+}
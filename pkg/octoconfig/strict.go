@@ -0,0 +1,283 @@
+package octoconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrUnresolved happens in strict template mode when a template references a missing key.
+var ErrUnresolved = errors.New("unresolved template reference")
+
+// noValue is what text/template renders for missing keys.
+const noValue = "<no value>"
+
+// guardFuncs are the template functions which handle missing values, their arguments aren't reported.
+//
+//nolint:gochecknoglobals
+var guardFuncs = []string{"default", "required", "empty", "coalesce"}
+
+// WithStrictTemplates reports every reference to a missing key in services, repos and files as error.
+func WithStrictTemplates(strict bool) Option {
+	return func(c *Config) {
+		c.strictTemplates = strict
+	}
+}
+
+// strict returns true if strict template mode is enabled by option or by `octoctl.strictTemplates`.
+func (c *Config) strict() bool {
+	return c.strictTemplates || (c.Octoctl != nil && c.Octoctl.StrictTemplates)
+}
+
+// execute executes t with data, in strict template mode it records every reference to a missing key.
+// source names the files defining the template.
+func (c *Config) execute(t *template.Template, data map[string]any, source string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return buf, err
+	}
+
+	c.checkStrict(t, data, buf.String(), source)
+
+	return buf, nil
+}
+
+// checkStrict records every reference of t to a key missing in data in strict template mode, output is the rendered t.
+func (c *Config) checkStrict(t *template.Template, data map[string]any, output string, source string) {
+	if !c.strict() {
+		return
+	}
+
+	errs := []error{}
+	filled := data
+
+	walkRefs(t.Tree, data, func(node parse.Node, dot refScope, keys []string, guarded bool) {
+		if guarded || !dot.known || hasPath(dot.value, keys) {
+			return
+		}
+
+		location, _ := t.Tree.ErrorContext(node)
+		errs = append(errs, fmt.Errorf("%w %s at %s, defined in %s", ErrUnresolved, node.String(), location, source))
+
+		filled = fillPath(filled, append(slices.Clone(dot.path), keys...))
+	})
+
+	// The references reported above render as empty, a remaining <no value> comes from a reference which can't
+	// be resolved without executing the template, for example inside range.
+	if len(errs) > 0 {
+		buf := &bytes.Buffer{}
+		if err := t.Execute(buf, filled); err == nil {
+			output = buf.String()
+		}
+	}
+
+	if strings.Contains(output, noValue) {
+		errs = append(errs, fmt.Errorf("%w, %s rendered %s, defined in %s", ErrUnresolved, t.Name(), noValue, source))
+	}
+
+	c.unresolved = append(c.unresolved, errs...)
+}
+
+// unresolvedErr returns all references to missing keys recorded by execute.
+func (c *Config) unresolvedErr() error {
+	if len(c.unresolved) == 0 {
+		return nil
+	}
+
+	return multierror.Append(nil, c.unresolved...)
+}
+
+// refScope represents dot while walking a template, path is the path of value in the template data.
+type refScope struct {
+	value any
+	path  []string
+	known bool
+}
+
+// refVisitor gets called for every reference to keys below dot, guarded references may be missing.
+type refVisitor func(node parse.Node, dot refScope, keys []string, guarded bool)
+
+// walkRefs calls visit for every field and `$` reference of tree which can be resolved without executing it.
+func walkRefs(tree *parse.Tree, data map[string]any, visit refVisitor) {
+	if tree == nil {
+		return
+	}
+
+	walker := &refWalker{root: refScope{value: data, known: true}, visit: visit}
+	walker.walk(tree.Root, walker.root)
+}
+
+// refWalker walks a template tree.
+type refWalker struct {
+	root  refScope
+	visit refVisitor
+}
+
+// walk walks node, dot isn't known when it can't be determined without executing the template.
+func (r *refWalker) walk(node parse.Node, dot refScope) {
+	switch typed := node.(type) {
+	case *parse.ListNode:
+		if typed == nil {
+			return
+		}
+
+		for _, child := range typed.Nodes {
+			r.walk(child, dot)
+		}
+	case *parse.ActionNode:
+		r.pipe(typed.Pipe, dot, false)
+	case *parse.TemplateNode:
+		r.pipe(typed.Pipe, dot, false)
+	case *parse.IfNode:
+		r.pipe(typed.Pipe, dot, true)
+		r.walk(typed.List, dot)
+		r.walk(typed.ElseList, dot)
+	case *parse.WithNode:
+		withDot := r.pipeScope(typed.Pipe, dot)
+		if !withDot.known {
+			r.pipe(typed.Pipe, dot, true)
+		}
+
+		r.walk(typed.List, withDot)
+		r.walk(typed.ElseList, dot)
+	case *parse.RangeNode:
+		r.pipe(typed.Pipe, dot, false)
+		r.walk(typed.List, refScope{})
+		r.walk(typed.ElseList, dot)
+	}
+}
+
+// pipe visits the arguments of all commands of pipe.
+func (r *refWalker) pipe(pipe *parse.PipeNode, dot refScope, guarded bool) {
+	if pipe == nil {
+		return
+	}
+
+	for idx, cmd := range pipe.Cmds {
+		// The result of a command gets passed as last argument to the next one, `.a | default "b"`.
+		cmdGuarded := guarded || (idx+1 < len(pipe.Cmds) && isGuard(pipe.Cmds[idx+1]))
+
+		for argIdx, arg := range cmd.Args {
+			r.arg(arg, dot, cmdGuarded || (argIdx > 0 && isGuard(cmd)))
+		}
+	}
+}
+
+// arg visits a single argument of a command.
+func (r *refWalker) arg(node parse.Node, dot refScope, guarded bool) {
+	switch typed := node.(type) {
+	case *parse.FieldNode:
+		r.visit(typed, dot, typed.Ident, guarded)
+	case *parse.VariableNode:
+		if len(typed.Ident) > 1 && typed.Ident[0] == "$" {
+			r.visit(typed, r.root, typed.Ident[1:], guarded)
+		}
+	case *parse.PipeNode:
+		r.pipe(typed, dot, guarded)
+	case *parse.ChainNode:
+		r.arg(typed.Node, dot, guarded)
+	}
+}
+
+// pipeScope returns the scope of a pipe which consists of a single existing field, `with .configs.penpot`.
+func (r *refWalker) pipeScope(pipe *parse.PipeNode, dot refScope) refScope {
+	if !dot.known || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return refScope{}
+	}
+
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok {
+		return refScope{}
+	}
+
+	value, ok := lookup(dot.value, field.Ident)
+	if !ok {
+		return refScope{}
+	}
+
+	return refScope{value: value, path: append(slices.Clone(dot.path), field.Ident...), known: true}
+}
+
+// hasPath returns false if one of keys is missing in value, values which aren't maps can't be checked.
+func hasPath(value any, keys []string) bool {
+	current := value
+
+	for _, key := range keys {
+		data, ok := current.(map[string]any)
+		if !ok {
+			return true
+		}
+
+		current, ok = data[key]
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fillPath returns a copy of data with an empty string at the missing path, the maps along path get copied.
+func fillPath(data map[string]any, path []string) map[string]any {
+	if len(path) == 0 {
+		return data
+	}
+
+	result := maps.Clone(data)
+
+	if len(path) == 1 {
+		if _, ok := result[path[0]]; !ok {
+			result[path[0]] = ""
+		}
+
+		return result
+	}
+
+	child, ok := result[path[0]]
+	if !ok {
+		child = map[string]any{}
+	}
+
+	if typed, ok := child.(map[string]any); ok {
+		result[path[0]] = fillPath(typed, path[1:])
+	}
+
+	return result
+}
+
+// lookup returns the value of keys in value, the result is unknown if a key is missing or not inside a map.
+func lookup(value any, keys []string) (any, bool) {
+	current := value
+
+	for _, key := range keys {
+		data, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = data[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// isGuard returns true if cmd calls one of the guardFuncs.
+func isGuard(cmd *parse.CommandNode) bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+
+	return ok && slices.Contains(guardFuncs, ident.Ident)
+}
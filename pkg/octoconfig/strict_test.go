@@ -0,0 +1,82 @@
+package octoconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictTemplates(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.strictTemplates = true
+	cfg.Data = map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{"environment": map[string]any{
+				"URI":   "{{ .configs.penpot.publicuri }}",
+				"FLAGS": `{{ .configs.penpot.flags | default "login" }}`,
+				"HOST":  "{{ with .configs.penpot }}{{ .host }}{{ .port }}{{ end }}",
+				"DB":    "{{ if .configs.postgres }}{{ $.configs.postgres.user }}{{ end }}",
+			}},
+		},
+		"configs": map[string]any{"penpot": map[string]any{"public_uri": "http://localhost", "host": "localhost"}},
+	}
+	cfg.provenance().record("", "", cfg.Data, "file:///charts/penpot.yaml", nil)
+
+	require.NoError(t, cfg.applyServiceTemplates())
+
+	err := cfg.unresolvedErr()
+	require.ErrorIs(t, err, ErrUnresolved)
+	require.Contains(t, err.Error(), "3 errors occurred")
+	require.Contains(t, err.Error(), ".configs.penpot.publicuri at services.penpot:")
+	require.Contains(t, err.Error(), ".port at services.penpot:")
+	require.Contains(t, err.Error(), "$.configs.postgres.user at services.penpot:")
+	require.Contains(t, err.Error(), "defined in file:///charts/penpot.yaml")
+	require.NotContains(t, err.Error(), ".flags")
+}
+
+func TestStrictTemplatesRange(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Octoctl = &OctoctlConfig{StrictTemplates: true}
+	cfg.Data = map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{"command": "{{ range .configs.penpot.flags }}{{ .name }}{{ end }}"},
+		},
+		"configs": map[string]any{"penpot": map[string]any{"flags": []any{map[string]any{"value": "login"}}}},
+	}
+
+	require.NoError(t, cfg.applyServiceTemplates())
+	require.ErrorIs(t, cfg.unresolvedErr(), ErrUnresolved)
+	require.Contains(t, cfg.unresolvedErr().Error(), "services.penpot rendered <no value>")
+}
+
+func TestStrictTemplatesMixed(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.strictTemplates = true
+	cfg.Data = map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{"command": "{{ .configs.penpot.publicuri }} {{ range .configs.penpot.flags }}{{ .name }}{{ end }}"},
+		},
+		"configs": map[string]any{"penpot": map[string]any{"flags": []any{map[string]any{"value": "login"}}}},
+	}
+
+	require.NoError(t, cfg.applyServiceTemplates())
+
+	// Both the reference found statically and the one inside range get reported.
+	err := cfg.unresolvedErr()
+	require.ErrorIs(t, err, ErrUnresolved)
+	require.Contains(t, err.Error(), "2 errors occurred")
+	require.Contains(t, err.Error(), ".configs.penpot.publicuri at services.penpot:")
+	require.Contains(t, err.Error(), "services.penpot rendered <no value>")
+}
+
+func TestStrictTemplatesDisabled(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"services": map[string]any{
+			"penpot": map[string]any{"command": "{{ .configs.penpot.publicuri }}"},
+		},
+	}
+
+	require.NoError(t, cfg.applyServiceTemplates())
+	require.NoError(t, cfg.unresolvedErr())
+}
@@ -0,0 +1,201 @@
+package octoconfig
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ErrTemplateCycle happens when templated values under `configs` or `globals` reference each other.
+var ErrTemplateCycle = errors.New("template cycle")
+
+// valueSections are the sections whose values can be templates.
+//
+//nolint:gochecknoglobals
+var valueSections = []string{"configs", "globals"}
+
+// templateValue represents a string value under one of the valueSections which contains a template.
+type templateValue struct {
+	path     string
+	text     string
+	template *template.Template
+	deps     []string
+
+	set func(value string)
+}
+
+// collectTemplateValues collects all string leaves of data which contain a template.
+func collectTemplateValues(path string, data any, set func(value string), result []*templateValue) []*templateValue {
+	switch typed := data.(type) {
+	case map[string]any:
+		for key, value := range typed {
+			result = collectTemplateValues(joinPath(path, key), value, func(value string) { typed[key] = value }, result)
+		}
+	case []any:
+		for idx, item := range typed {
+			result = collectTemplateValues(fmt.Sprintf("%s[%d]", path, idx), item, func(value string) { typed[idx] = value }, result)
+		}
+	case string:
+		if strings.Contains(typed, "{{") {
+			result = append(result, &templateValue{path: path, text: typed, set: set})
+		}
+	}
+
+	return result
+}
+
+// resolveValues renders the templates in the values under `configs` and `globals` in dependency order,
+// it repeats until no value changes to resolve references templates can't be checked for.
+func (c *Config) resolveValues() error {
+	values := []*templateValue{}
+
+	for _, section := range valueSections {
+		if data, ok := c.Data[section].(map[string]any); ok {
+			values = collectTemplateValues(section, data, nil, values)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(values, func(a, b *templateValue) int {
+		return strings.Compare(a.path, b.path)
+	})
+
+	templateVars := c.TemplateVars()
+
+	for _, value := range values {
+		t, err := c.NewTemplate(value.path).Parse(value.text)
+		if err != nil {
+			return fmt.Errorf("while parsing template %s defined in %s: %w", value.path, c.sources(value.path), err)
+		}
+
+		value.template = t
+		value.deps = templateDeps(t.Tree, templateVars)
+	}
+
+	ordered, err := orderValues(values)
+	if err != nil {
+		return err
+	}
+
+	rendered := map[string]string{}
+	changed := []string{}
+
+	// A pass without changes proves the fixed point, values referencing each other dynamically never settle.
+	for range len(ordered) + 1 {
+		changed = changed[:0]
+
+		for _, value := range ordered {
+			buf := &strings.Builder{}
+			if err := value.template.Execute(buf, templateVars); err != nil {
+				return fmt.Errorf("while executing template %s defined in %s: %w", value.path, c.sources(value.path), err)
+			}
+
+			if previous, ok := rendered[value.path]; !ok || previous != buf.String() {
+				changed = append(changed, value.path)
+			}
+
+			rendered[value.path] = buf.String()
+			value.set(buf.String())
+		}
+
+		if len(changed) == 0 {
+			for _, value := range ordered {
+				c.checkStrict(value.template, templateVars, rendered[value.path], c.sources(value.path))
+			}
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: the values of %s never settle", ErrTemplateCycle, strings.Join(changed, ", "))
+}
+
+// templateDeps returns the paths of the values the template tree references.
+func templateDeps(tree *parse.Tree, data map[string]any) []string {
+	deps := []string{}
+
+	walkRefs(tree, data, func(_ parse.Node, dot refScope, keys []string, _ bool) {
+		if !dot.known {
+			return
+		}
+
+		path := ""
+		for _, key := range append(slices.Clone(dot.path), keys...) {
+			path = joinPath(path, key)
+		}
+
+		if !slices.Contains(deps, path) {
+			deps = append(deps, path)
+		}
+	})
+
+	return deps
+}
+
+// orderValues returns values with each value after the values it depends on.
+func orderValues(values []*templateValue) ([]*templateValue, error) {
+	const (
+		visiting = iota + 1
+		done
+	)
+
+	state := map[string]int{}
+	ordered := make([]*templateValue, 0, len(values))
+
+	var visit func(value *templateValue, chain []string) error
+
+	visit = func(value *templateValue, chain []string) error {
+		chain = append(chain, value.path)
+
+		switch state[value.path] {
+		case visiting:
+			start := slices.Index(chain, value.path)
+			return fmt.Errorf("%w: %s", ErrTemplateCycle, strings.Join(chain[start:], " -> "))
+		case done:
+			return nil
+		}
+
+		state[value.path] = visiting
+
+		for _, dep := range values {
+			// A value referencing its parent map doesn't depend on itself.
+			if dep == value || !dependsOn(value, dep.path) {
+				continue
+			}
+
+			if err := visit(dep, chain); err != nil {
+				return err
+			}
+		}
+
+		state[value.path] = done
+		ordered = append(ordered, value)
+
+		return nil
+	}
+
+	for _, value := range values {
+		if err := visit(value, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// dependsOn returns true if value references path, its parents or its children.
+func dependsOn(value *templateValue, path string) bool {
+	for _, dep := range value.deps {
+		if below(path, dep) || below(dep, path) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package octoconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveValues(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"configs": map[string]any{
+			"penpot": map[string]any{
+				"postgres": map[string]any{
+					"url":  "postgresql://{{ .configs.postgres.user }}@{{ .configs.postgres.host }}/penpot",
+					"urls": []any{"{{ .configs.penpot.postgres.url }}"},
+				},
+				"host": "{{ with .configs.penpot }}{{ .postgres.url }}{{ end }}",
+			},
+			"postgres": map[string]any{
+				"user": "{{ .globals.postgres.user }}",
+				"host": "postgres",
+			},
+		},
+		"globals": map[string]any{
+			"postgres": map[string]any{"user": "penpot"},
+		},
+	}
+
+	require.NoError(t, cfg.resolveValues())
+
+	penpot := cfg.Data["configs"].(map[string]any)["penpot"].(map[string]any)
+	require.Equal(t, "postgresql://penpot@postgres/penpot", penpot["postgres"].(map[string]any)["url"])
+	require.Equal(t, []any{"postgresql://penpot@postgres/penpot"}, penpot["postgres"].(map[string]any)["urls"])
+	require.Equal(t, "postgresql://penpot@postgres/penpot", penpot["host"])
+}
+
+func TestResolveValuesCycle(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"configs": map[string]any{
+			"a": "{{ .configs.b }}",
+			"b": "{{ .configs.a }}",
+		},
+	}
+
+	err := cfg.resolveValues()
+	require.ErrorIs(t, err, ErrTemplateCycle)
+	require.Contains(t, err.Error(), "configs.a -> configs.b -> configs.a")
+}
+
+func TestResolveValuesNeverSettle(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Data = map[string]any{
+		"configs": map[string]any{
+			"a": "x{{ range $k, $v := .configs }}{{ if eq $k \"a\" }}{{ $v }}{{ end }}{{ end }}",
+		},
+	}
+
+	err := cfg.resolveValues()
+	require.ErrorIs(t, err, ErrTemplateCycle)
+	require.Contains(t, err.Error(), "configs.a never settle")
+}
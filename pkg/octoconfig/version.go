@@ -0,0 +1,160 @@
+package octoconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-orb/go-orb/config"
+	"github.com/octocompose/octoctl/pkg/octocache"
+)
+
+// ErrNoMatchingVersion happens when no version of a versions index matches the constraint of an include.
+var ErrNoMatchingVersion = errors.New("no matching version")
+
+// ResolvedVersion records which version has been chosen for an include.
+type ResolvedVersion struct {
+	Constraint string `json:"constraint"`
+	Index      string `json:"index"`
+	Version    string `json:"version"`
+	URL        string `json:"url"`
+}
+
+// versionsIndex represents the versions index of an include.
+type versionsIndex struct {
+	Versions []string `json:"versions"`
+}
+
+// matchVersion returns the highest version matching the constraint, as written in the index.
+func matchVersion(constraint string, versions []string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("while parsing version constraint '%s': %w", constraint, err)
+	}
+
+	var (
+		best    *semver.Version
+		bestRaw string
+	)
+
+	for _, raw := range versions {
+		v, err := semver.NewVersion(raw)
+		if err != nil {
+			// Ignore entries which are not semantic versions.
+			continue
+		}
+
+		if !c.Check(v) {
+			continue
+		}
+
+		if best == nil || v.GreaterThan(best) {
+			best = v
+			bestRaw = raw
+		}
+	}
+
+	if best == nil {
+		return "", fmt.Errorf("%w for '%s'", ErrNoMatchingVersion, constraint)
+	}
+
+	return bestRaw, nil
+}
+
+// latestVersion returns the highest version of the versions index of include matching its constraint, the index
+// gets fetched on every run so new releases get picked up.
+func (c *Config) latestVersion(ctx context.Context, include *urlConfig) (string, error) {
+	cached, err := octocache.FetchURL(ctx, c.ProjectID, include.Versions.URL, "configs")
+	if err != nil {
+		return "", fmt.Errorf("while fetching versions index '%s': %w", include.Versions.URL.String(), err)
+	}
+
+	data, err := config.Read(cached.URL)
+	if err != nil {
+		return "", fmt.Errorf("while reading versions index '%s': %w", include.Versions.URL.String(), err)
+	}
+
+	index := versionsIndex{}
+	if err := config.Parse(nil, "", data, &index); err != nil {
+		return "", fmt.Errorf("while parsing versions index '%s': %w", include.Versions.URL.String(), err)
+	}
+
+	version, err := matchVersion(include.Version, index.Versions)
+	if err != nil {
+		return "", fmt.Errorf("while resolving versions index '%s': %w", include.Versions.URL.String(), err)
+	}
+
+	return version, nil
+}
+
+// resolveVersion sets the URL of include to the highest version of its versions index matching its constraint,
+// a version pinned in the lock takes precedence unless the lock gets updated.
+func (c *Config) resolveVersion(ctx context.Context, include *urlConfig, base *url.URL) error {
+	if include.Versions.URL == nil || include.Versions.Format == "" {
+		return fmt.Errorf("include with version '%s' requires versions.url and versions.format", include.Version)
+	}
+
+	AbsURL(include.Versions.URL.URL, base)
+
+	lock := octocache.LockFromContext(ctx)
+
+	version := ""
+
+	if lock != nil {
+		if pinned, ok := lock.PinnedVersion(include.Versions.URL.String(), include.Version); ok {
+			version = pinned.Version
+		}
+	}
+
+	if version == "" {
+		var err error
+
+		version, err = c.latestVersion(ctx, include)
+		if err != nil {
+			return err
+		}
+	}
+
+	t, err := NewTemplate("versions.format").Parse(include.Versions.Format)
+	if err != nil {
+		return fmt.Errorf("while parsing versions format '%s': %w", include.Versions.Format, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, map[string]any{"version": version}); err != nil {
+		return fmt.Errorf("while executing versions format '%s': %w", include.Versions.Format, err)
+	}
+
+	include.URL, err = config.NewURL(buf.String())
+	if err != nil {
+		return fmt.Errorf("while parsing versioned URL '%s': %w", buf.String(), err)
+	}
+
+	AbsURL(include.URL.URL, base)
+
+	if lock != nil {
+		lock.SetVersion(octocache.LockVersion{
+			Index:      include.Versions.URL.String(),
+			Constraint: include.Version,
+			Version:    version,
+			URL:        include.URL.String(),
+		})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ResolvedVersions = append(c.ResolvedVersions, ResolvedVersion{
+		Constraint: include.Version,
+		Index:      include.Versions.URL.String(),
+		Version:    version,
+		URL:        include.URL.String(),
+	})
+
+	c.logger.Info("Resolved include version", "constraint", include.Version, "version", version, "url", include.URL.String())
+
+	return nil
+}
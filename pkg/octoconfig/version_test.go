@@ -0,0 +1,114 @@
+package octoconfig
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/octocompose/octoctl/pkg/octocache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchVersion(t *testing.T) {
+	versions := []string{"v1.3.9", "v1.4.0", "v1.4.2", "v1.10.1", "v2.0.0", "latest"}
+
+	version, err := matchVersion("^1.4", versions)
+	require.NoError(t, err)
+	require.Equal(t, "v1.10.1", version)
+
+	version, err = matchVersion("~1.4", versions)
+	require.NoError(t, err)
+	require.Equal(t, "v1.4.2", version)
+
+	_, err = matchVersion("^3", versions)
+	require.ErrorIs(t, err, ErrNoMatchingVersion)
+}
+
+func TestIncludeVersion(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `include:
+  - version: "^1.4"
+    versions:
+      url: ./versions.yaml
+      format: "./chart-{{ .version }}.yaml"
+`,
+		"versions.yaml":    "versions:\n  - 1.4.0\n  - 1.5.0\n  - 2.0.0\n",
+		"chart-1.5.0.yaml": "chart: \"1.5.0\"\n",
+		"chart-1.4.0.yaml": "chart: \"1.4.0\"\n",
+		"chart-2.0.0.yaml": "chart: \"2.0.0\"\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+
+	require.Equal(t, "1.5.0", cfg.Data["chart"])
+	require.Len(t, cfg.ResolvedVersions, 1)
+	require.Equal(t, "1.5.0", cfg.ResolvedVersions[0].Version)
+	require.Equal(t, "file://"+filepath.Join(dir, "chart-1.5.0.yaml"), cfg.ResolvedVersions[0].URL)
+}
+
+func TestIncludeVersionRefresh(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	versions := atomic.Value{}
+	versions.Store("1.4.0")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/versions.yaml" {
+			_, _ = fmt.Fprintf(w, "versions: [%s]\n", versions.Load()) //nolint:errcheck
+			return
+		}
+
+		version := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/chart-"), ".yaml")
+		_, _ = fmt.Fprintf(w, "chart: %q\n", version) //nolint:errcheck
+	}))
+	t.Cleanup(server.Close)
+
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `include:
+  - version: "^1.4"
+    gpg: none
+    versions:
+      url: ` + server.URL + `/versions.yaml
+      format: "` + server.URL + `/chart-{{ .version }}.yaml"
+`,
+	})
+
+	resolve := func(lock *octocache.Lock) any {
+		t.Helper()
+
+		ctx := t.Context()
+		if lock != nil {
+			ctx = octocache.WithLock(ctx, lock)
+		}
+
+		cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+		require.NoError(t, cfg.read(ctx))
+		require.NoError(t, cfg.merge(ctx))
+
+		return cfg.Data["chart"]
+	}
+
+	require.Equal(t, "1.4.0", resolve(nil))
+
+	// The index isn't cached, a new release gets picked up.
+	versions.Store("1.4.0, 1.5.0")
+	require.Equal(t, "1.5.0", resolve(nil))
+
+	// The lock pins the version until it gets updated.
+	lock := octocache.NewLock(filepath.Join(dir, octocache.LockFileName))
+	require.Equal(t, "1.5.0", resolve(lock))
+
+	versions.Store("1.4.0, 1.5.0, 1.6.0")
+	require.Equal(t, "1.5.0", resolve(lock))
+	require.Equal(t, "1.5.0", lock.Versions[server.URL+"/versions.yaml#^1.4"].Version)
+
+	lock.SetUpdate(true)
+	require.Equal(t, "1.6.0", resolve(lock))
+	require.Equal(t, server.URL+"/chart-1.6.0.yaml", lock.Versions[server.URL+"/versions.yaml#^1.4"].URL)
+}
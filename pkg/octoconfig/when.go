@@ -0,0 +1,263 @@
+package octoconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrWhen happens when the `when` condition of an include can't be evaluated.
+var ErrWhen = errors.New("invalid when condition")
+
+// whenPathPattern matches conditions which are a plain path like `configs.penpot.smtp.enabled`, optionally negated.
+//
+//nolint:gochecknoglobals
+var whenPathPattern = regexp.MustCompile(`^(!?)\s*([A-Za-z_][A-Za-z0-9_-]*(?:\.[A-Za-z0-9_-]+)*)$`)
+
+// pendingInclude represents an include with a `when` condition which hasn't been evaluated yet.
+type pendingInclude struct {
+	when   string
+	source string
+
+	// read reads the include in place of its placeholder.
+	read func(ctx context.Context) error
+	// skip removes the placeholder of the include.
+	skip func()
+}
+
+// deferInclude reads include once its condition holds, a placeholder keeps its position in the includes of parent.
+func (c *Config) deferInclude(parent *urlConfig, include *urlConfig, chain []string) {
+	include.pending = true
+	parent.Includes = append(parent.Includes, include)
+
+	c.addPending(&pendingInclude{
+		when:   include.When,
+		source: parent.URL.String(),
+		read: func(ctx context.Context) error {
+			include.pending = false
+
+			result, err := c.readInclude(ctx, parent, include, chain)
+
+			idx := slices.Index(parent.Includes, include)
+			parent.Includes = slices.Replace(parent.Includes, idx, idx+1, result...)
+
+			return err
+		},
+		skip: func() {
+			parent.Includes = slices.DeleteFunc(parent.Includes, func(known *urlConfig) bool { return known == include })
+		},
+	})
+}
+
+// deferRepo calls read once the condition holds, the repository read takes the position of a placeholder in
+// the children of parent.
+func (c *Config) deferRepo(when string, source string, parent *Repo, read func(ctx context.Context) (*Repo, error)) {
+	placeholder := &Repo{pending: true}
+	parent.Children = append(parent.Children, placeholder)
+
+	c.addPending(&pendingInclude{
+		when:   when,
+		source: source,
+		read: func(ctx context.Context) error {
+			repo, err := read(ctx)
+
+			idx := slices.Index(parent.Children, placeholder)
+			if repo != nil {
+				parent.Children[idx] = repo
+			} else {
+				parent.Children = slices.Delete(parent.Children, idx, idx+1)
+			}
+
+			return err
+		},
+		skip: func() {
+			parent.Children = slices.DeleteFunc(parent.Children, func(known *Repo) bool { return known == placeholder })
+		},
+	})
+}
+
+// addPending adds an include to evaluate once everything else has been read.
+func (c *Config) addPending(pending *pendingInclude) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending = append(c.pending, pending)
+}
+
+// readPending reads the includes whose condition holds against the values known so far, until no further
+// condition holds. The includes left get skipped.
+func (c *Config) readPending(ctx context.Context) error {
+	mErr := &multierror.Error{}
+	taken := []*pendingInclude{}
+
+	for len(c.pending) > 0 {
+		vars, err := c.knownValues(ctx)
+		if err != nil {
+			return err
+		}
+
+		ready := []*pendingInclude{}
+		waiting := []*pendingInclude{}
+
+		for _, pending := range c.pending {
+			ok, err := c.evalWhen(pending.when, vars)
+			if err != nil {
+				mErr = multierror.Append(mErr, fmt.Errorf("while evaluating an include of '%s': %w", pending.source, err))
+				pending.skip()
+
+				continue
+			}
+
+			if ok {
+				ready = append(ready, pending)
+			} else {
+				waiting = append(waiting, pending)
+			}
+		}
+
+		c.pending = waiting
+
+		if len(ready) == 0 {
+			break
+		}
+
+		taken = append(taken, ready...)
+
+		for _, pending := range ready {
+			c.logger.Trace("Reading conditional include", "when", pending.when, "source", pending.source)
+
+			if err := pending.read(ctx); err != nil {
+				mErr = multierror.Append(mErr, err)
+			}
+		}
+	}
+
+	for _, pending := range c.pending {
+		c.logger.Debug("Skipping conditional include", "when", pending.when, "source", pending.source)
+		pending.skip()
+	}
+
+	c.pending = nil
+
+	if err := c.recheckWhen(ctx, taken); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+// recheckWhen fails if the condition of an include which has been read doesn't hold against the final values,
+// for example `!configs.penpot.ldap.enabled` when a later conditional include enables ldap.
+func (c *Config) recheckWhen(ctx context.Context, taken []*pendingInclude) error {
+	if len(taken) == 0 {
+		return nil
+	}
+
+	vars, err := c.knownValues(ctx)
+	if err != nil {
+		return err
+	}
+
+	mErr := &multierror.Error{}
+
+	for _, pending := range taken {
+		if ok, err := c.evalWhen(pending.when, vars); err == nil && !ok {
+			mErr = multierror.Append(mErr, fmt.Errorf(
+				"%w '%s' of an include of '%s': it held when the include was read, but not with the values of the includes read later",
+				ErrWhen, pending.when, pending.source,
+			))
+		}
+	}
+
+	return mErr.ErrorOrNil()
+}
+
+// knownValues returns the template variables with the data of all files read so far merged, the merged state
+// of c is left untouched.
+func (c *Config) knownValues(ctx context.Context) (map[string]any, error) {
+	data, provenance, strategies, octoctl := c.Data, c.Provenance, c.strategies, c.Octoctl
+
+	defer func() {
+		c.Data, c.Provenance, c.strategies, c.Octoctl = data, provenance, strategies, octoctl
+	}()
+
+	c.Data = map[string]any{}
+	c.Provenance = nil
+
+	if err := c.merge(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.TemplateVars(), nil
+}
+
+// evalWhen evaluates a condition, a plain path like `configs.penpot.smtp.enabled` or `!env.CI` is true if the
+// value is set and not false, empty or zero. Everything else is a template pipeline like `eq .OS "linux"`.
+func (c *Config) evalWhen(when string, vars map[string]any) (bool, error) {
+	when = strings.TrimSpace(when)
+
+	if match := whenPathPattern.FindStringSubmatch(when); match != nil {
+		value, _ := lookupPath(vars, strings.Split(match[2], "."))
+
+		return truthy(value) != (match[1] == "!"), nil
+	}
+
+	t, err := c.NewTemplate("when").Parse("{{ if " + when + " }}true{{ end }}")
+	if err != nil {
+		return false, fmt.Errorf("%w '%s': %w", ErrWhen, when, err)
+	}
+
+	buf := &strings.Builder{}
+	if err := t.Execute(buf, vars); err != nil {
+		return false, fmt.Errorf("%w '%s': %w", ErrWhen, when, err)
+	}
+
+	return buf.String() == "true", nil
+}
+
+// lookupPath returns the value at keys in data.
+func lookupPath(data any, keys []string) (any, bool) {
+	for _, key := range keys {
+		typed, ok := data.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if data, ok = typed[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return data, true
+}
+
+// truthy returns false for nil, false, zero, empty values and strings which parse as false.
+func truthy(value any) bool {
+	if value == nil {
+		return false
+	}
+
+	if s, ok := value.(string); ok {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+
+		return s != ""
+	}
+
+	rv := reflect.ValueOf(value)
+
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Map, reflect.Slice:
+		return rv.Len() > 0
+	}
+
+	return !rv.IsZero()
+}
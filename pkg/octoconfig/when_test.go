@@ -0,0 +1,121 @@
+package octoconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalIncludes(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `include:
+  - url: ./all.yaml
+configs:
+  penpot:
+    smtp:
+      enabled: true
+    ldap:
+      enabled: "false"
+`,
+		"all.yaml": `include:
+  - url: ./base.yaml
+  - url: ./smtp.yaml
+    when: configs.penpot.smtp.enabled
+  - url: ./ldap.yaml
+    when: configs.penpot.ldap.enabled
+  - url: ./mailcatcher.yaml
+    when: configs.mailcatcher.enabled
+  - url: ./os.yaml
+    when: ne .OS ""
+repos:
+  include:
+    - url: ./repo-ldap.yaml
+      when: "!configs.penpot.ldap.enabled"
+    - url: ./repo-smtp.yaml
+      when: "!configs.penpot.smtp.enabled"
+`,
+		"base.yaml":        "list:\n  - base\n",
+		"smtp.yaml":        "list:\n  - smtp\nconfigs:\n  mailcatcher:\n    enabled: true\n",
+		"ldap.yaml":        "list:\n  - ldap\n",
+		"mailcatcher.yaml": "list:\n  - mailcatcher\n",
+		"os.yaml":          "list:\n  - os\n",
+		"repo-ldap.yaml":   "services:\n  openldap:\n    docker:\n      registry: docker.io\n      image: osixia/openldap\n      tag: latest\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+
+	require.NoError(t, cfg.read(t.Context()))
+	require.NoError(t, cfg.merge(t.Context()))
+
+	// Includes keep their position, the mailcatcher gets enabled by the smtp include.
+	require.Equal(t, []any{"os", "mailcatcher", "smtp", "base"}, cfg.Data["list"])
+
+	repos := []string{}
+	for _, repo := range cfg.collectRepos() {
+		repos = append(repos, repo.URL.String())
+	}
+
+	require.Contains(t, repos, "file://"+filepath.Join(dir, "repo-ldap.yaml"))
+	require.NotContains(t, repos, "file://"+filepath.Join(dir, "repo-smtp.yaml"))
+}
+
+func TestConditionalIncludeNoLongerHolds(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": `include:
+  - url: ./ldap.yaml
+    when: configs.penpot.sso
+  - url: ./local-users.yaml
+    when: "!configs.penpot.ldap.enabled"
+configs:
+  penpot:
+    sso: true
+`,
+		"ldap.yaml":        "configs:\n  penpot:\n    ldap:\n      enabled: true\n",
+		"local-users.yaml": "list:\n  - local\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+
+	// Both conditions hold in the first pass, ldap.yaml then enables ldap.
+	err := cfg.read(t.Context())
+	require.ErrorIs(t, err, ErrWhen)
+	require.Contains(t, err.Error(), "!configs.penpot.ldap.enabled")
+}
+
+func TestConditionalIncludeInvalid(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.yaml": "include:\n  - url: ./other.yaml\n    when: eq .OS\n",
+	})
+
+	cfg := testConfigForFile(t, filepath.Join(dir, "main.yaml"))
+
+	require.ErrorIs(t, cfg.read(t.Context()), ErrWhen)
+	require.Empty(t, cfg.Paths[0].Includes)
+}
+
+func TestEvalWhen(t *testing.T) {
+	cfg := setupTestConfig()
+	vars := map[string]any{
+		"OS":      "linux",
+		"env":     map[string]any{"CI": "false", "DEBUG": "1"},
+		"configs": map[string]any{"empty": map[string]any{}, "port": 0, "name": "penpot"},
+	}
+
+	for when, expected := range map[string]bool{
+		"env.CI":                    false,
+		"!env.CI":                   true,
+		"env.DEBUG":                 true,
+		"env.MISSING":               false,
+		"configs.empty":             false,
+		"configs.port":              false,
+		"configs.name":              true,
+		`eq .OS "linux"`:            true,
+		`and .configs.name .env.CI`: true,
+		`eq .configs.name "other"`:  false,
+	} {
+		result, err := cfg.evalWhen(when, vars)
+		require.NoError(t, err, when)
+		require.Equal(t, expected, result, when)
+	}
+}
@@ -0,0 +1,593 @@
+// Package octooci pulls and pushes charts as OCI artifacts with the registry HTTP API.
+package octooci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Media types of chart artifacts.
+const (
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeEmpty    = "application/vnd.oci.empty.v1+json"
+	MediaTypeLayerTar = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeLayerGz  = "application/vnd.oci.image.layer.v1.tar+gzip"
+	ArtifactTypeChart = "application/vnd.octocompose.chart.v1"
+)
+
+const (
+	mediaTypeDockerV2 = "application/vnd.docker.distribution.manifest.v2+json"
+	annotationTitle   = "org.opencontainers.image.title"
+	defaultTag        = "latest"
+	digestAlgorithm   = "sha256:"
+	schemeOCI         = "oci"
+	pullScope         = "pull"
+	pushScope         = "pull,push"
+	maxManifestSize   = 4 << 20
+)
+
+// Environment variables with the credentials for registries.
+const (
+	EnvUsername = "OCTOCTL_REGISTRY_USERNAME"
+	EnvPassword = "OCTOCTL_REGISTRY_PASSWORD" //nolint:gosec
+)
+
+var (
+	// ErrReference happens on invalid OCI references.
+	ErrReference = errors.New("invalid OCI reference")
+	// ErrDigestMismatch happens when a manifest or blob doesn't match its digest.
+	ErrDigestMismatch = errors.New("digest mismatch")
+	// ErrRegistry happens when the registry answers with an unexpected status.
+	ErrRegistry = errors.New("registry error")
+	// ErrUnsafePath happens when a layer contains a path outside of the artifact.
+	ErrUnsafePath = errors.New("unsafe path in layer")
+)
+
+// Reference represents an artifact, given as `oci://registry/org/penpot:1.2.0//config/all.yaml`.
+type Reference struct {
+	// Registry is the host and port of the registry.
+	Registry string
+	// Repository is the name of the repository, e.g. `org/penpot`.
+	Repository string
+	// Reference is the tag or the digest, it defaults to `latest`.
+	Reference string
+	// File is the path of a file inside the artifact, it's optional.
+	File string
+}
+
+// IsOCIURL returns true if u has the `oci` scheme.
+func IsOCIURL(u *url.URL) bool {
+	return u.Scheme == schemeOCI
+}
+
+// ParseReference parses an `oci://` URL.
+func ParseReference(u *url.URL) (*Reference, error) {
+	if !IsOCIURL(u) {
+		return nil, fmt.Errorf("%w '%s': the scheme has to be '%s'", ErrReference, u.String(), schemeOCI)
+	}
+
+	name, file, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "//")
+
+	result := &Reference{Registry: u.Host, Repository: name, Reference: defaultTag}
+
+	if file != "" {
+		result.File = path.Clean(file)
+	}
+
+	if repo, digest, ok := strings.Cut(name, "@"); ok {
+		result.Repository = repo
+		result.Reference = digest
+	} else if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		result.Repository = name[:idx]
+		result.Reference = name[idx+1:]
+	}
+
+	if result.Registry == "" || result.Repository == "" || result.Reference == "" {
+		return nil, fmt.Errorf("%w '%s': expected 'oci://registry/repository:tag'", ErrReference, u.String())
+	}
+
+	return result, nil
+}
+
+// ParseReferenceString parses an `oci://` URL given as string.
+func ParseReferenceString(s string) (*Reference, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w '%s': %w", ErrReference, s, err)
+	}
+
+	return ParseReference(u)
+}
+
+// IsDigest returns true if the reference is a digest instead of a tag.
+func (r *Reference) IsDigest() bool {
+	return strings.HasPrefix(r.Reference, digestAlgorithm)
+}
+
+// String returns the reference without the file.
+func (r *Reference) String() string {
+	if r.IsDigest() {
+		return r.Registry + "/" + r.Repository + "@" + r.Reference
+	}
+
+	return r.Registry + "/" + r.Repository + ":" + r.Reference
+}
+
+// Descriptor describes a blob of an artifact.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest represents an OCI image manifest.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Client talks to OCI registries.
+type Client struct {
+	httpClient *http.Client
+	username   string
+	password   string
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client, http.DefaultClient by default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBasicAuth sets the credentials for the registry, without them the client pulls anonymously.
+func WithBasicAuth(username string, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithEnvironment reads the credentials from OCTOCTL_REGISTRY_USERNAME and OCTOCTL_REGISTRY_PASSWORD.
+func WithEnvironment() Option {
+	return WithBasicAuth(os.Getenv(EnvUsername), os.Getenv(EnvPassword))
+}
+
+// NewClient creates a new registry client.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+		tokens:     map[string]string{},
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// Resolve fetches the manifest of ref and returns it with its digest.
+func (c *Client) Resolve(ctx context.Context, ref *Reference) (*Manifest, string, error) {
+	resp, err := c.do(ctx, ref, pullScope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(ref, "manifests", ref.Reference), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", MediaTypeManifest+", "+mediaTypeDockerV2)
+
+		return req, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", statusErr(resp, "while fetching the manifest of "+ref.String())
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", err
+	}
+
+	digest := digestOf(b)
+
+	if ref.IsDigest() && digest != ref.Reference {
+		return nil, "", fmt.Errorf("%w: manifest of %s has digest %s", ErrDigestMismatch, ref.String(), digest)
+	}
+
+	if header := resp.Header.Get("Docker-Content-Digest"); header != "" && header != digest {
+		return nil, "", fmt.Errorf("%w: manifest of %s has digest %s, the registry claims %s", ErrDigestMismatch, ref.String(), digest, header)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, "", fmt.Errorf("while parsing the manifest of %s: %w", ref.String(), err)
+	}
+
+	return manifest, digest, nil
+}
+
+// Unpack downloads the layers of manifest, verifies their digests and extracts them into dir. Tar layers
+// get extracted, other layers are written to the file named by their title annotation.
+func (c *Client) Unpack(ctx context.Context, ref *Reference, manifest *Manifest, dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	for _, layer := range manifest.Layers {
+		if err := c.unpackLayer(ctx, ref, layer, dir); err != nil {
+			return fmt.Errorf("while unpacking layer %s of %s: %w", layer.Digest, ref.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// Pull resolves ref and unpacks its layers into dir, it returns the digest of the manifest.
+func (c *Client) Pull(ctx context.Context, ref *Reference, dir string) (string, error) {
+	manifest, digest, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return digest, c.Unpack(ctx, ref, manifest, dir)
+}
+
+func (c *Client) unpackLayer(ctx context.Context, ref *Reference, layer Descriptor, dir string) error {
+	// Download to a temporary file first, nothing gets extracted before the digest is verified.
+	blob, err := os.CreateTemp("", "octoctl-blob-")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := blob.Close(); err != nil {
+			slog.Error("Error while closing the blob", "file", blob.Name(), "error", err)
+		}
+
+		if err := os.Remove(blob.Name()); err != nil {
+			slog.Error("Error while removing the blob", "file", blob.Name(), "error", err)
+		}
+	}()
+
+	if err := c.fetchBlob(ctx, ref, layer, blob); err != nil {
+		return err
+	}
+
+	if _, err := blob.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	switch layer.MediaType {
+	case MediaTypeLayerGz:
+		gz, err := gzip.NewReader(blob)
+		if err != nil {
+			return err
+		}
+
+		return extractTar(gz, dir)
+	case MediaTypeLayerTar:
+		return extractTar(blob, dir)
+	}
+
+	title := layer.Annotations[annotationTitle]
+	if title == "" {
+		return nil
+	}
+
+	target, err := safeJoin(dir, title)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(target, blob, 0o600)
+}
+
+// fetchBlob downloads the blob of desc into w and verifies its digest.
+func (c *Client) fetchBlob(ctx context.Context, ref *Reference, desc Descriptor, w io.Writer) error {
+	resp, err := c.do(ctx, ref, pullScope, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(ref, "blobs", desc.Digest), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return statusErr(resp, "while fetching blob "+desc.Digest)
+	}
+
+	hash := sha256.New()
+
+	n, err := io.Copy(io.MultiWriter(w, hash), resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if digest := digestAlgorithm + hex.EncodeToString(hash.Sum(nil)); digest != desc.Digest || n != desc.Size {
+		return fmt.Errorf("%w: blob %s has digest %s and size %d", ErrDigestMismatch, desc.Digest, digest, n)
+	}
+
+	return nil
+}
+
+// extractTar extracts the regular files and directories of a tar stream into dir.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		target, err := safeJoin(dir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(header.Mode).Perm()|0o600); err != nil { //nolint:gosec
+				return err
+			}
+		default:
+			slog.Debug("Skipping tar entry", "name", header.Name, "type", header.Typeflag)
+		}
+	}
+}
+
+// safeJoin joins name to dir, it fails if name points outside of dir.
+func safeJoin(dir string, name string) (string, error) {
+	cleaned := path.Clean(filepath.ToSlash(name))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: '%s'", ErrUnsafePath, name)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(cleaned)), nil
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+
+	fp, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fp, r); err != nil { //nolint:gosec
+		_ = fp.Close() //nolint:errcheck
+
+		return err
+	}
+
+	return fp.Close()
+}
+
+// endpoint returns the URL of a registry API endpoint of the repository.
+func (c *Client) endpoint(ref *Reference, kind string, name string) string {
+	return registryURL(ref.Registry) + "/v2/" + ref.Repository + "/" + kind + "/" + name
+}
+
+// registryURL returns the base URL of a registry, local registries are talked to with plain HTTP.
+func registryURL(registry string) string {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+
+	if host == "localhost" {
+		return "http://" + registry
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http://" + registry
+	}
+
+	return "https://" + registry
+}
+
+// do sends the request built by newReq, it authenticates and retries once when the registry asks for it.
+func (c *Client) do(ctx context.Context, ref *Reference, scope string, newReq func() (*http.Request, error)) (*http.Response, error) {
+	tokenKey := ref.Registry + "/" + ref.Repository + ":" + scope
+
+	req, err := newReq()
+	if err != nil {
+		return nil, err
+	}
+
+	c.authorize(req, tokenKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	closeBody(resp)
+
+	if err := c.authenticate(ctx, ref, scope, tokenKey, challenge); err != nil {
+		return nil, err
+	}
+
+	req, err = newReq()
+	if err != nil {
+		return nil, err
+	}
+
+	c.authorize(req, tokenKey)
+
+	return c.httpClient.Do(req)
+}
+
+// authorize adds the token or the credentials to req.
+func (c *Client) authorize(req *http.Request, tokenKey string) {
+	c.mu.Lock()
+	token, ok := c.tokens[tokenKey]
+	c.mu.Unlock()
+
+	switch {
+	case ok && token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case ok && c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// authenticate answers the challenge of the registry, Bearer challenges get a token from the realm.
+func (c *Client) authenticate(ctx context.Context, ref *Reference, scope string, tokenKey string, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+
+	if !strings.EqualFold(scheme, "bearer") {
+		if c.username == "" {
+			return fmt.Errorf("%w: %s requires credentials, set %s and %s", ErrRegistry, ref.Registry, EnvUsername, EnvPassword)
+		}
+
+		c.mu.Lock()
+		c.tokens[tokenKey] = ""
+		c.mu.Unlock()
+
+		return nil
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("%w: invalid challenge '%s'", ErrRegistry, challenge)
+	}
+
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+
+	query.Set("scope", "repository:"+ref.Repository+":"+scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return statusErr(resp, "while fetching a token for "+ref.Registry)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"` //nolint:tagliatelle
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("while parsing the token of %s: %w", ref.Registry, err)
+	}
+
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	c.mu.Lock()
+	c.tokens[tokenKey] = token.Token
+	c.mu.Unlock()
+
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header like `Bearer realm="https://auth",service="registry"`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var key, value string
+
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	return scheme, params
+}
+
+// digestOf returns the sha256 digest of b.
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return digestAlgorithm + hex.EncodeToString(sum[:])
+}
+
+func statusErr(resp *http.Response, action string) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint:errcheck
+	return fmt.Errorf("%w %s: status %s: %s", ErrRegistry, action, resp.Status, strings.TrimSpace(string(b)))
+}
+
+func closeBody(resp *http.Response) {
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		slog.Error("Error while reading the body", "url", resp.Request.URL.String(), "error", err)
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		slog.Error("Error while closing the body", "url", resp.Request.URL.String(), "error", err)
+	}
+}
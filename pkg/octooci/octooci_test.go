@@ -0,0 +1,173 @@
+package octooci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is a minimal in-memory registry implementing the endpoints the client uses.
+type testRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	token     string
+}
+
+func newTestRegistry(t *testing.T, token string) (*testRegistry, string) {
+	t.Helper()
+
+	registry := &testRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}, token: token}
+
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+
+	return registry, strings.TrimPrefix(server.URL, "http://")
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		_, _ = fmt.Fprintf(w, `{"token": "%s"}`, r.token) //nolint:errcheck
+		return
+	}
+
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test"`, req.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	rest := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	switch {
+	case strings.HasSuffix(rest, "/blobs/uploads/") && req.Method == http.MethodPost:
+		w.Header().Set("Location", "/upload/1")
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(req.URL.Path, "/upload/") && req.Method == http.MethodPut:
+		b, _ := io.ReadAll(req.Body) //nolint:errcheck
+		r.blobs[req.URL.Query().Get("digest")] = b
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(rest, "/blobs/"):
+		b, ok := r.blobs[rest[strings.LastIndex(rest, "/")+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(b) //nolint:errcheck
+	case strings.Contains(rest, "/manifests/") && req.Method == http.MethodPut:
+		b, _ := io.ReadAll(req.Body) //nolint:errcheck
+		r.manifests[rest[strings.LastIndex(rest, "/")+1:]] = b
+		r.manifests[digestOf(b)] = b
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(rest, "/manifests/"):
+		b, ok := r.manifests[rest[strings.LastIndex(rest, "/")+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(b) //nolint:errcheck
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testReference(t *testing.T, s string) *Reference {
+	t.Helper()
+
+	ref, err := ParseReferenceString(s)
+	require.NoError(t, err)
+
+	return ref
+}
+
+func TestParseReference(t *testing.T) {
+	ref := testReference(t, "oci://registry.example.com:5000/org/penpot:1.2.0//config/all.yaml")
+	require.Equal(t, "registry.example.com:5000", ref.Registry)
+	require.Equal(t, "org/penpot", ref.Repository)
+	require.Equal(t, "1.2.0", ref.Reference)
+	require.Equal(t, "config/all.yaml", ref.File)
+
+	ref = testReference(t, "oci://registry.example.com/org/penpot")
+	require.Equal(t, "latest", ref.Reference)
+	require.Empty(t, ref.File)
+
+	ref = testReference(t, "oci://registry.example.com/org/penpot@sha256:abcd//all.yaml")
+	require.True(t, ref.IsDigest())
+	require.Equal(t, "sha256:abcd", ref.Reference)
+
+	u, err := url.Parse("https://registry.example.com/org/penpot")
+	require.NoError(t, err)
+
+	_, err = ParseReference(u)
+	require.ErrorIs(t, err, ErrReference)
+}
+
+func TestPushPull(t *testing.T) {
+	for _, token := range []string{"", "s3cr3t"} {
+		registry, host := newTestRegistry(t, token)
+
+		src := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "config"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "config", "all.yaml"), []byte("include: []\n"), 0o600))
+
+		client := NewClient()
+		ref := testReference(t, "oci://"+host+"/org/penpot:1.2.0")
+
+		digest, err := client.Push(context.Background(), ref, src)
+		require.NoError(t, err)
+
+		// Pushing the same directory again gives the same digest.
+		again, err := client.Push(context.Background(), ref, src)
+		require.NoError(t, err)
+		require.Equal(t, digest, again)
+
+		dst := t.TempDir()
+
+		pulled, err := NewClient().Pull(context.Background(), ref, dst)
+		require.NoError(t, err)
+		require.Equal(t, digest, pulled)
+
+		b, err := os.ReadFile(filepath.Join(dst, "config", "all.yaml"))
+		require.NoError(t, err)
+		require.Equal(t, "include: []\n", string(b))
+
+		// Tampered blobs are rejected.
+		for key := range registry.blobs {
+			if key != digestOf([]byte(emptyConfig)) {
+				registry.blobs[key] = []byte("tampered")
+			}
+		}
+
+		_, err = NewClient().Pull(context.Background(), ref, t.TempDir())
+		require.ErrorIs(t, err, ErrDigestMismatch)
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	dir := t.TempDir()
+
+	joined, err := safeJoin(dir, "./config/all.yaml")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "config", "all.yaml"), joined)
+
+	for _, name := range []string{"../evil", "/etc/passwd", "config/../../evil"} {
+		_, err := safeJoin(dir, name)
+		require.ErrorIs(t, err, ErrUnsafePath, name)
+	}
+}
@@ -0,0 +1,213 @@
+package octooci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// chartLayerTitle is the title of the layer containing the chart directory.
+const chartLayerTitle = "chart.tar.gz"
+
+// emptyConfig is the config blob of chart artifacts.
+const emptyConfig = "{}"
+
+// Push packs dir into a reproducible tar.gz layer and pushes it as chart artifact to ref, it returns the
+// digest of the manifest.
+func (c *Client) Push(ctx context.Context, ref *Reference, dir string) (string, error) {
+	layer, err := packDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("while packing '%s': %w", dir, err)
+	}
+
+	manifest := Manifest{
+		SchemaVersion: 2, //nolint:mnd
+		MediaType:     MediaTypeManifest,
+		ArtifactType:  ArtifactTypeChart,
+		Config: Descriptor{
+			MediaType: MediaTypeEmpty,
+			Digest:    digestOf([]byte(emptyConfig)),
+			Size:      int64(len(emptyConfig)),
+		},
+		Layers: []Descriptor{{
+			MediaType:   MediaTypeLayerGz,
+			Digest:      digestOf(layer),
+			Size:        int64(len(layer)),
+			Annotations: map[string]string{annotationTitle: chartLayerTitle},
+		}},
+	}
+
+	if err := c.pushBlob(ctx, ref, manifest.Config, []byte(emptyConfig)); err != nil {
+		return "", err
+	}
+
+	if err := c.pushBlob(ctx, ref, manifest.Layers[0], layer); err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, ref, pushScope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.endpoint(ref, "manifests", ref.Reference), bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", MediaTypeManifest)
+
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", statusErr(resp, "while pushing the manifest of "+ref.String())
+	}
+
+	return digestOf(b), nil
+}
+
+// pushBlob uploads a blob in a single request unless the registry already has it.
+func (c *Client) pushBlob(ctx context.Context, ref *Reference, desc Descriptor, b []byte) error {
+	resp, err := c.do(ctx, ref, pushScope, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodHead, c.endpoint(ref, "blobs", desc.Digest), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	closeBody(resp)
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = c.do(ctx, ref, pushScope, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(ref, "blobs", "uploads/"), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	closeBody(resp)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return statusErr(resp, "while starting the upload of blob "+desc.Digest)
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("%w: invalid upload location: %w", ErrRegistry, err)
+	}
+
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = c.do(ctx, ref, pushScope, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/octet-stream")
+
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusCreated {
+		return statusErr(resp, "while uploading blob "+desc.Digest)
+	}
+
+	return nil
+}
+
+// packDir returns the regular files and directories of dir as tar.gz, the archive only depends on the
+// names and contents of the files so pushing the same directory twice gives the same digest.
+func packDir(dir string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		header := &tar.Header{Name: filepath.ToSlash(rel)}
+
+		switch {
+		case entry.IsDir():
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			header.Mode = 0o755
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			header.Typeflag = tar.TypeReg
+			header.Mode = 0o644
+			header.Size = info.Size()
+		default:
+			return nil
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		fp, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return err
+		}
+
+		defer fp.Close() //nolint:errcheck
+
+		_, err = io.Copy(tw, fp)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,415 @@
+// Package octosecrets encrypts and decrypts configuration values with age.
+package octosecrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables containing the age key or the path to the age key file.
+const (
+	EnvKey     = "OCTOCTL_AGE_KEY"
+	EnvKeyFile = "OCTOCTL_AGE_KEY_FILE"
+)
+
+// Encrypted values are written as `ENC[age,<base64 age ciphertext of the JSON encoded value>]`.
+const (
+	encPrefix = "ENC[age,"
+	encSuffix = "]"
+)
+
+// ErrNoIdentity happens when encrypted values have to be decrypted without an age key.
+var ErrNoIdentity = errors.New("no age key to decrypt secrets, set " + EnvKey + " or " + EnvKeyFile)
+
+// ErrNoRecipient happens when values have to be encrypted without a recipient.
+var ErrNoRecipient = errors.New("no age recipient to encrypt secrets")
+
+// DefaultKeyFile returns the path of the default age key file.
+func DefaultKeyFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "octocompose", "keys", "age.txt"), nil
+}
+
+// LoadIdentities loads the age identities from the environment or keyFile.
+// An empty keyFile falls back to EnvKeyFile and then to the default key file, a missing default key file is no error.
+func LoadIdentities(keyFile string) ([]age.Identity, error) {
+	if key := os.Getenv(EnvKey); key != "" {
+		identities, err := age.ParseIdentities(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("while parsing %s: %w", EnvKey, err)
+		}
+
+		return identities, nil
+	}
+
+	explicit := true
+	if keyFile == "" {
+		keyFile = os.Getenv(EnvKeyFile)
+	}
+
+	if keyFile == "" {
+		explicit = false
+
+		var err error
+
+		keyFile, err = DefaultKeyFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fp, err := os.Open(keyFile) //nolint:gosec
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("while opening age key file '%s': %w", keyFile, err)
+	}
+
+	defer func() {
+		if err := fp.Close(); err != nil {
+			slog.Error("Error while closing the age key file", "file", keyFile, "error", err)
+		}
+	}()
+
+	identities, err := age.ParseIdentities(fp)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing age key file '%s': %w", keyFile, err)
+	}
+
+	return identities, nil
+}
+
+// Recipients parses recipients, without recipients it returns the recipients of the X25519 identities.
+func Recipients(identities []age.Identity, recipients []string) ([]age.Recipient, error) {
+	result := []age.Recipient{}
+
+	for _, recipient := range recipients {
+		r, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing recipient '%s': %w", recipient, err)
+		}
+
+		result = append(result, r)
+	}
+
+	if len(result) > 0 {
+		return result, nil
+	}
+
+	for _, identity := range identities {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			result = append(result, x25519.Recipient())
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, ErrNoRecipient
+	}
+
+	return result, nil
+}
+
+// IsEncrypted returns true if value is an encrypted value.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encPrefix) && strings.HasSuffix(value, encSuffix)
+}
+
+// EncryptValue encrypts value for recipients.
+func EncryptValue(value any, recipients []age.Recipient) (string, error) {
+	plain, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+
+	w, err := age.Encrypt(buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := w.Write(plain); err != nil {
+		return "", err
+	}
+
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return encPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + encSuffix, nil
+}
+
+// DecryptValue decrypts an encrypted value.
+func DecryptValue(value string, identities []age.Identity) (any, error) {
+	if len(identities) == 0 {
+		return nil, ErrNoIdentity
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), encSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("while decoding encrypted value: %w", err)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("while decrypting value: %w", err)
+	}
+
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("while decrypting value: %w", err)
+	}
+
+	var result any
+	if err := json.Unmarshal(plain, &result); err != nil {
+		return nil, fmt.Errorf("while decoding decrypted value: %w", err)
+	}
+
+	return result, nil
+}
+
+// Contains returns true if data contains encrypted values.
+func Contains(data any) bool {
+	switch typed := data.(type) {
+	case map[string]any:
+		for _, value := range typed {
+			if Contains(value) {
+				return true
+			}
+		}
+	case []any:
+		for _, value := range typed {
+			if Contains(value) {
+				return true
+			}
+		}
+	case string:
+		return IsEncrypted(typed)
+	}
+
+	return false
+}
+
+// Decrypt decrypts all encrypted values in data in place.
+func Decrypt(data any, identities []age.Identity) (any, error) {
+	switch typed := data.(type) {
+	case map[string]any:
+		for key, value := range typed {
+			decrypted, err := Decrypt(value, identities)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+
+			typed[key] = decrypted
+		}
+	case []any:
+		for idx, value := range typed {
+			decrypted, err := Decrypt(value, identities)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", idx, err)
+			}
+
+			typed[idx] = decrypted
+		}
+	case string:
+		if IsEncrypted(typed) {
+			return DecryptValue(typed, identities)
+		}
+	}
+
+	return data, nil
+}
+
+// walkScalars calls fn for every scalar value of a YAML node with its path and key.
+func walkScalars(path string, key string, node *yaml.Node, fn func(path string, key string, node *yaml.Node) error) error {
+	switch node.Kind { //nolint:exhaustive
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := walkScalars(path, key, child, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			childKey := node.Content[idx].Value
+
+			childPath := childKey
+			if path != "" {
+				childPath = path + "." + childKey
+			}
+
+			if err := walkScalars(childPath, childKey, node.Content[idx+1], fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for idx, child := range node.Content {
+			if err := walkScalars(fmt.Sprintf("%s[%d]", path, idx), key, child, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
+		}
+
+		return fn(path, key, node)
+	}
+
+	return nil
+}
+
+// Leaves returns the paths of all scalar values in a YAML document.
+func Leaves(b []byte) ([]string, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	err := walkScalars("", "", doc, func(path string, _ string, _ *yaml.Node) error {
+		result = append(result, path)
+		return nil
+	})
+
+	return result, err
+}
+
+// structuralKeys are the top level keys of a config file octoctl reads without decrypting them.
+//
+//nolint:gochecknoglobals
+var structuralKeys = []string{"name", "include", "repos", "versions"}
+
+// includeKeys are the keys of includes which decide what gets read, at any depth.
+//
+//nolint:gochecknoglobals
+var includeKeys = []string{"when", "vars", "into"}
+
+// structural returns true if path belongs to the structure of a config file rather than to its values.
+func structural(path string) bool {
+	for idx, part := range strings.Split(path, ".") {
+		key, _, _ := strings.Cut(part, "[")
+
+		if idx == 0 && slices.Contains(structuralKeys, key) {
+			return true
+		}
+
+		if slices.Contains(includeKeys, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EncryptYAML encrypts the values of a YAML document match returns true for, comments are kept.
+// Values which are encrypted already stay as they are, the structure of a config file like `name`,
+// `include` or `repos` never gets encrypted.
+func EncryptYAML(b []byte, recipients []age.Recipient, match func(path string, key string) bool) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+
+	err := walkScalars("", "", doc, func(path string, key string, node *yaml.Node) error {
+		if IsEncrypted(node.Value) || structural(path) || !match(path, key) {
+			return nil
+		}
+
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		encrypted, err := EncryptValue(value, recipients)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		node.Value = encrypted
+		node.Tag = "!!str"
+		node.Style = 0
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeYAML(doc)
+}
+
+// DecryptYAML decrypts all encrypted values of a YAML document, comments are kept.
+// It returns the paths of the decrypted values.
+func DecryptYAML(b []byte, identities []age.Identity) ([]byte, []string, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, nil, err
+	}
+
+	paths := []string{}
+	err := walkScalars("", "", doc, func(path string, _ string, node *yaml.Node) error {
+		if !IsEncrypted(node.Value) {
+			return nil
+		}
+
+		value, err := DecryptValue(node.Value, identities)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		decrypted := &yaml.Node{}
+		if err := decrypted.Encode(value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		decrypted.HeadComment = node.HeadComment
+		decrypted.LineComment = node.LineComment
+		decrypted.FootComment = node.FootComment
+		*node = *decrypted
+
+		paths = append(paths, path)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := encodeYAML(doc)
+
+	return out, paths, err
+}
+
+func encodeYAML(doc *yaml.Node) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
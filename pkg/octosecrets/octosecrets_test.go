@@ -0,0 +1,116 @@
+package octosecrets
+
+import (
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func testKeys(t *testing.T) ([]age.Identity, []age.Recipient) {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	recipients, err := Recipients([]age.Identity{identity}, nil)
+	require.NoError(t, err)
+
+	return []age.Identity{identity}, recipients
+}
+
+func TestEncryptValue(t *testing.T) {
+	identities, recipients := testKeys(t)
+
+	encrypted, err := EncryptValue(587, recipients)
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(encrypted))
+
+	data := map[string]any{"smtp": map[string]any{"port": encrypted, "host": "mail"}}
+	require.True(t, Contains(data))
+
+	_, err = Decrypt(data, nil)
+	require.ErrorIs(t, err, ErrNoIdentity)
+
+	decrypted, err := Decrypt(data, identities)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"smtp": map[string]any{"port": float64(587), "host": "mail"}}, decrypted)
+	require.False(t, Contains(decrypted))
+}
+
+func TestEncryptYAML(t *testing.T) {
+	identities, recipients := testKeys(t)
+
+	plain := "smtp:\n  # The password.\n  password: hunter2 # secret\n  host: mail\n"
+
+	encrypted, err := EncryptYAML([]byte(plain), recipients, func(_ string, key string) bool { return key == "password" })
+	require.NoError(t, err)
+	require.NotContains(t, string(encrypted), "hunter2")
+	require.Contains(t, string(encrypted), "host: mail")
+
+	decrypted, paths, err := DecryptYAML(encrypted, identities)
+	require.NoError(t, err)
+	require.Equal(t, []string{"smtp.password"}, paths)
+	require.Equal(t, plain, string(decrypted))
+}
+
+func TestEncryptYAMLStructure(t *testing.T) {
+	_, recipients := testKeys(t)
+
+	plain := `name: penpot
+include:
+  - url: ./postgres.yaml
+    into: configs.db
+    vars:
+      name: db
+    when: configs.db.enabled
+versions:
+  format: v{{ .version }}
+repos:
+  include:
+    - url: ./repo.yaml
+configs:
+  db:
+    password: hunter2
+`
+
+	encrypted, err := EncryptYAML([]byte(plain), recipients, func(string, string) bool { return true })
+	require.NoError(t, err)
+
+	want := map[string]any{}
+	require.NoError(t, yaml.Unmarshal([]byte(plain), &want))
+
+	got := map[string]any{}
+	require.NoError(t, yaml.Unmarshal(encrypted, &got))
+
+	// Only the value got encrypted.
+	password := got["configs"].(map[string]any)["db"].(map[string]any)["password"] //nolint:forcetypeassert
+	require.True(t, IsEncrypted(password.(string)))                                //nolint:forcetypeassert
+
+	delete(want, "configs")
+	delete(got, "configs")
+	require.Equal(t, want, got)
+}
+
+func TestLoadIdentities(t *testing.T) {
+	t.Setenv(EnvKey, "")
+	t.Setenv(EnvKeyFile, "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	// No default key file.
+	identities, err := LoadIdentities("")
+	require.NoError(t, err)
+	require.Empty(t, identities)
+
+	_, err = LoadIdentities(t.TempDir() + "/missing.txt")
+	require.Error(t, err)
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	t.Setenv(EnvKey, identity.String())
+
+	identities, err = LoadIdentities("")
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+}
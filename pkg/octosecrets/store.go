@@ -0,0 +1,268 @@
+package octosecrets
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of generated secrets.
+const (
+	TypeSecret = "secret"
+	TypeUUID   = "uuid"
+	TypeRSAKey = "rsaKey"
+)
+
+// Defaults of generated secrets.
+const (
+	DefaultSecretLength = 32
+	DefaultRSABits      = 2048
+)
+
+// secretAlphabet is the alphabet of generated secrets, it's safe in URLs, environment variables and YAML.
+const secretAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// ErrUnknownSecret happens when rotating a secret which hasn't been generated.
+var ErrUnknownSecret = errors.New("unknown secret")
+
+// Generated represents a generated secret.
+type Generated struct {
+	Type    string    `json:"type"`
+	Value   string    `json:"value"`
+	Length  int       `json:"length,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Store persists the generated secrets of a project, it's not part of the cache.
+type Store struct {
+	path    string
+	mu      sync.Mutex
+	secrets map[string]Generated
+}
+
+// StorePath returns the path of the generated secrets of a project.
+func StorePath(projectID string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "octocompose", "secrets", projectID+".json"), nil
+}
+
+// OpenStore reads the generated secrets of a project.
+func OpenStore(projectID string) (*Store, error) {
+	path, err := StorePath(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{path: path, secrets: map[string]Generated{}}
+
+	b, err := os.ReadFile(path) //nolint:gosec
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("while reading generated secrets '%s': %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &store.secrets); err != nil {
+		return nil, fmt.Errorf("while parsing generated secrets '%s': %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Path returns the path of the store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// List returns the names of all generated secrets sorted.
+func (s *Store) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(s.secrets))
+}
+
+// Get returns a generated secret.
+func (s *Store) Get(name string) (Generated, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[name]
+
+	return secret, ok
+}
+
+// Secret returns the random alphanumeric secret name, it gets generated with length on first use.
+func (s *Store) Secret(name string, length int) (string, error) {
+	return s.getOrGenerate(name, TypeSecret, length)
+}
+
+// UUID returns the random UUID name, it gets generated on first use.
+func (s *Store) UUID(name string) (string, error) {
+	return s.getOrGenerate(name, TypeUUID, 0)
+}
+
+// RSAKey returns the PEM encoded RSA private key name, it gets generated on first use.
+func (s *Store) RSAKey(name string) (string, error) {
+	return s.getOrGenerate(name, TypeRSAKey, DefaultRSABits)
+}
+
+// RSAPublicKey returns the PEM encoded public key of the RSA private key name.
+func (s *Store) RSAPublicKey(name string) (string, error) {
+	private, err := s.RSAKey(name)
+	if err != nil {
+		return "", err
+	}
+
+	block, _ := pem.Decode([]byte(private))
+	if block == nil {
+		return "", fmt.Errorf("rsa key '%s' is not PEM encoded", name)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("while parsing rsa key '%s': %w", name, err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("key '%s' is not an rsa key", name)
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})), nil
+}
+
+// Rotate generates new values for the secrets names.
+func (s *Store) Rotate(names ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		secret, ok := s.secrets[name]
+		if !ok {
+			return fmt.Errorf("%w '%s'", ErrUnknownSecret, name)
+		}
+
+		rotated, err := generate(secret.Type, secret.Length)
+		if err != nil {
+			return fmt.Errorf("while rotating '%s': %w", name, err)
+		}
+
+		s.secrets[name] = rotated
+	}
+
+	return s.save()
+}
+
+func (s *Store) getOrGenerate(name string, typ string, length int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if secret, ok := s.secrets[name]; ok {
+		if secret.Type != typ {
+			return "", fmt.Errorf("secret '%s' has been generated as %s, not as %s", name, secret.Type, typ)
+		}
+
+		return secret.Value, nil
+	}
+
+	secret, err := generate(typ, length)
+	if err != nil {
+		return "", fmt.Errorf("while generating '%s': %w", name, err)
+	}
+
+	s.secrets[name] = secret
+
+	if err := s.save(); err != nil {
+		return "", err
+	}
+
+	return secret.Value, nil
+}
+
+// save writes the store with private permissions.
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("while creating '%s': %w", filepath.Dir(s.path), err)
+	}
+
+	b, err := json.MarshalIndent(s.secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("while writing generated secrets '%s': %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("while writing generated secrets '%s': %w", s.path, err)
+	}
+
+	return nil
+}
+
+// generate generates a new secret of typ.
+func generate(typ string, length int) (Generated, error) {
+	result := Generated{Type: typ, Length: length, Created: time.Now().UTC()}
+
+	switch typ {
+	case TypeSecret:
+		if length <= 0 {
+			return result, fmt.Errorf("invalid secret length %d", length)
+		}
+
+		value := make([]byte, length)
+		for idx := range value {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(secretAlphabet))))
+			if err != nil {
+				return result, err
+			}
+
+			value[idx] = secretAlphabet[n.Int64()]
+		}
+
+		result.Value = string(value)
+	case TypeUUID:
+		result.Value = uuid.NewString()
+	case TypeRSAKey:
+		key, err := rsa.GenerateKey(rand.Reader, length)
+		if err != nil {
+			return result, err
+		}
+
+		b, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return result, err
+		}
+
+		result.Value = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}))
+	default:
+		return result, fmt.Errorf("unknown secret type '%s'", typ)
+	}
+
+	return result, nil
+}
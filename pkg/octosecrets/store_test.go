@@ -0,0 +1,63 @@
+package octosecrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	store, err := OpenStore("penpot")
+	require.NoError(t, err)
+
+	password, err := store.Secret("postgres_password", 24)
+	require.NoError(t, err)
+	require.Len(t, password, 24)
+
+	instance, err := store.UUID("instance")
+	require.NoError(t, err)
+
+	_, err = store.UUID("postgres_password")
+	require.Error(t, err)
+
+	// A new store reads the persisted values.
+	store, err = OpenStore("penpot")
+	require.NoError(t, err)
+	require.Equal(t, []string{"instance", "postgres_password"}, store.List())
+
+	again, err := store.Secret("postgres_password", 24)
+	require.NoError(t, err)
+	require.Equal(t, password, again)
+
+	again, err = store.UUID("instance")
+	require.NoError(t, err)
+	require.Equal(t, instance, again)
+
+	require.NoError(t, store.Rotate("postgres_password"))
+
+	rotated, err := store.Secret("postgres_password", 24)
+	require.NoError(t, err)
+	require.Len(t, rotated, 24)
+	require.NotEqual(t, password, rotated)
+
+	require.ErrorIs(t, store.Rotate("unknown"), ErrUnknownSecret)
+}
+
+func TestStoreRSAKey(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	store, err := OpenStore("penpot")
+	require.NoError(t, err)
+
+	private, err := store.RSAKey("jwt")
+	require.NoError(t, err)
+	require.Contains(t, private, "BEGIN PRIVATE KEY")
+
+	public, err := store.RSAPublicKey("jwt")
+	require.NoError(t, err)
+	require.Contains(t, public, "BEGIN PUBLIC KEY")
+}